@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/intertwin-eu/interlink/pkg/interlink/api"
+)
+
+// Generates the OpenAPI documents of the InterLink API and of the sidecar API from the Go types
+func main() {
+	interLinkOut := flag.String("interlink", "docs/openapi/interlink-openapi.json", "path of the InterLink API document")
+	sidecarOut := flag.String("sidecar", "docs/openapi/sidecar-openapi.json", "path of the sidecar API document")
+	flag.Parse()
+
+	for path, document := range map[string]map[string]interface{}{
+		*interLinkOut: api.InterLinkOpenAPI(),
+		*sidecarOut:   api.SidecarOpenAPI(),
+	} {
+		content, err := json.MarshalIndent(document, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		err = os.WriteFile(path, append(content, '\n'), 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
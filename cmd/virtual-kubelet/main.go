@@ -206,10 +206,11 @@ func main() {
 	// TODO: disable this through options
 	trace.T = opentelemetry.Adapter{}
 
-	// TODO: if token specified http.DefaultClient = ...
-	// and remove reading from file
-
-	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	// Without TLS options InterLink certificates are not verified, to stay compatible with previous deployments.
+	// Set TLS.CACertFile (and TLS.CertFile/TLS.KeyFile for mutual TLS) in the VK config to verify them.
+	if !interLinkConfig.TLS.Enabled() {
+		http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
 
 	dport, err := strconv.ParseInt(os.Getenv("KUBELET_PORT"), 10, 32)
 	if err != nil {
@@ -300,6 +301,9 @@ func main() {
 		ServiceInformer:   scmInformerFactory.Core().V1().Services(),
 	}
 
+	// the pods waiting for their ConfigMaps and Secrets are woken up by the same informers
+	nodeProvider.WatchDependencies(podControllerConfig.ConfigMapInformer, podControllerConfig.SecretInformer)
+
 	// stop signal for the informer
 	stopper := make(chan struct{})
 	defer close(stopper)
@@ -309,7 +313,11 @@ func main() {
 	go scmInformerFactory.Start(stopper)
 
 	// start to sync and call list
-	if !cache.WaitForCacheSync(stopper, podInformerFactory.Core().V1().Pods().Informer().HasSynced) {
+	if !cache.WaitForCacheSync(stopper,
+		podInformerFactory.Core().V1().Pods().Informer().HasSynced,
+		podControllerConfig.ConfigMapInformer.Informer().HasSynced,
+		podControllerConfig.SecretInformer.Informer().HasSynced,
+	) {
 		log.G(ctx).Fatal(fmt.Errorf("timed out waiting for caches to sync"))
 		return
 	}
@@ -326,17 +334,23 @@ func main() {
 
 	// start podHandler
 	handlerPodConfig := api.PodHandlerConfig{
-		GetContainerLogs: nodeProvider.GetLogs,
-		GetPods:          nodeProvider.GetPods,
-		GetStatsSummary:  nodeProvider.GetStatsSummary,
+		RunInContainer:    nodeProvider.RunInContainer,
+		AttachToContainer: nodeProvider.AttachToContainer,
+		PortForward:       nodeProvider.PortForward,
+		GetContainerLogs:  nodeProvider.GetLogs,
+		GetPods:           nodeProvider.GetPods,
+		GetStatsSummary:   nodeProvider.GetStatsSummary,
 	}
 
 	mux := http.NewServeMux()
 
 	podRoutes := api.PodHandlerConfig{
-		GetContainerLogs: handlerPodConfig.GetContainerLogs,
-		GetStatsSummary:  handlerPodConfig.GetStatsSummary,
-		GetPods:          handlerPodConfig.GetPods,
+		RunInContainer:    handlerPodConfig.RunInContainer,
+		AttachToContainer: handlerPodConfig.AttachToContainer,
+		PortForward:       handlerPodConfig.PortForward,
+		GetContainerLogs:  handlerPodConfig.GetContainerLogs,
+		GetStatsSummary:   handlerPodConfig.GetStatsSummary,
+		GetPods:           handlerPodConfig.GetPods,
 	}
 
 	api.AttachPodRoutes(podRoutes, mux, true)
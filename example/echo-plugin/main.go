@@ -0,0 +1,116 @@
+// Command echo-plugin is a minimal sidecar plugin, meant to test InterLink locally.
+// Pods are only kept in memory and reported as running, while their forwarded ports are served by local TCP servers:
+// by default a built-in echo server, so that kubectl port-forward can be tried without any remote resource.
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/containerd/containerd/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+	"github.com/intertwin-eu/interlink/pkg/plugin"
+)
+
+// echoProvider runs no workload: every port of its Pods is forwarded to target, on the given port
+type echoProvider struct {
+	target string
+	mu     sync.Mutex
+	pods   map[string]*v1.Pod
+}
+
+func (p *echoProvider) Create(ctx context.Context, pod commonIL.RetrievedPodData) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pods[string(pod.Pod.UID)] = pod.Pod.DeepCopy()
+	return nil
+}
+
+func (p *echoProvider) Delete(ctx context.Context, pod *v1.Pod) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pods, string(pod.UID))
+	return nil
+}
+
+func (p *echoProvider) Status(ctx context.Context, pod *v1.Pod) (commonIL.PodStatus, error) {
+	p.mu.Lock()
+	_, found := p.pods[string(pod.UID)]
+	p.mu.Unlock()
+	if !found {
+		return commonIL.PodStatus{}, plugin.ErrPodNotFound
+	}
+
+	status := commonIL.PodStatus{PodName: pod.Name, PodUID: string(pod.UID), PodNamespace: pod.Namespace}
+	for _, container := range pod.Spec.Containers {
+		status.Containers = append(status.Containers, v1.ContainerStatus{
+			Name:  container.Name,
+			Image: container.Image,
+			Ready: true,
+			State: v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: metav1.Now()}},
+		})
+	}
+	return status, nil
+}
+
+func (p *echoProvider) Logs(ctx context.Context, req commonIL.LogStruct) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (p *echoProvider) PortForward(ctx context.Context, req commonIL.PortForwardRequest, stream io.ReadWriteCloser) error {
+	return plugin.ForwardTo(ctx, net.JoinHostPort(p.target, strconv.Itoa(int(req.Port))), stream)
+}
+
+// serveEcho echoes back everything received by the connections accepted on addr and returns the address it listens on
+func serveEcho(ctx context.Context, addr string) (net.Addr, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	log.G(ctx).Info("Echo server listening on " + listener.Addr().String())
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				log.G(ctx).Error(err)
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr(), nil
+}
+
+func main() {
+	addr := flag.String("listen", "127.0.0.1:4000", "address the plugin listens on, either host:port or unix://path")
+	target := flag.String("target", "127.0.0.1", "host the forwarded ports of the Pods are connected to")
+	echo := flag.String("echo", "127.0.0.1:8080", "address of the built-in echo server, empty to disable it")
+	flag.Parse()
+
+	ctx := context.Background()
+	if *echo != "" {
+		_, err := serveEcho(ctx, *echo)
+		if err != nil {
+			log.G(ctx).Fatal(err)
+		}
+	}
+
+	provider := &echoProvider{target: *target, pods: map[string]*v1.Pod{}}
+	log.G(ctx).Info("Echo plugin listening on " + *addr)
+	err := plugin.ListenAndServe(ctx, *addr, provider)
+	if err != nil {
+		log.G(ctx).Fatal(err)
+	}
+}
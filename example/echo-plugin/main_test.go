@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+	"github.com/intertwin-eu/interlink/pkg/interlink/api"
+	"github.com/intertwin-eu/interlink/pkg/interlink/client"
+	"github.com/intertwin-eu/interlink/pkg/plugin"
+)
+
+// startInterLink serves the echo plugin, and InterLink in front of it, with httptest and returns a client of InterLink
+func startInterLink(t *testing.T) *client.Client {
+	t.Helper()
+	ctx := context.Background()
+
+	provider := &echoProvider{target: "127.0.0.1", pods: map[string]*v1.Pod{}}
+	sidecar := httptest.NewServer(plugin.NewSidecarHandler(ctx, provider).Mux())
+	t.Cleanup(sidecar.Close)
+
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(sidecar.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := commonIL.InterLinkConfig{Sidecarurl: "http://" + host, Sidecarport: port}
+	router, err := api.NewSidecarRouter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	handler := &api.InterLinkHandler{Config: config, Ctx: ctx, Sidecars: router}
+	handler.RegisterRoutes(mux)
+	interLink := httptest.NewServer(mux)
+	t.Cleanup(interLink.Close)
+
+	return client.NewClient(interLink.URL)
+}
+
+// portForward opens a port forward stream to port through InterLink
+func portForward(t *testing.T, interLinkClient *client.Client, port int) (*commonIL.StreamConn, io.ReadWriteCloser) {
+	t.Helper()
+	conn, err := interLinkClient.PortForward(context.Background(), commonIL.PortForwardRequest{Namespace: "default", PodName: "echo", PodUID: "uid", Port: int32(port)})
+	if err != nil {
+		t.Fatalf("unable to open the port forward stream: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, commonIL.NewPortForwardStream(conn)
+}
+
+// expectClosed fails unless the websocket of the stream is closed within a few seconds
+func expectClosed(t *testing.T, conn *commonIL.StreamConn) {
+	t.Helper()
+	closed := make(chan error, 1)
+	go func() {
+		for {
+			_, _, err := conn.ReadFrame()
+			if err != nil {
+				closed <- err
+				return
+			}
+		}
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream has not been closed")
+	}
+}
+
+func TestPortForwardEcho(t *testing.T) {
+	echoAddr, err := serveEcho(context.Background(), "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, stream := portForward(t, startInterLink(t), echoAddr.(*net.TCPAddr).Port)
+
+	_, err = stream.Write([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	echoed := make([]byte, 5)
+	_, err = io.ReadFull(stream, echoed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(echoed) != "hello" {
+		t.Errorf("expected hello to be echoed, got %q", echoed)
+	}
+
+	// half-closing the stream ends the echo connection, which in turn closes the stream
+	err = stream.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("expected the end of the echoed data, got %v", err)
+	}
+	if len(rest) != 0 {
+		t.Errorf("expected nothing more to be echoed, got %q", rest)
+	}
+	expectClosed(t, conn)
+}
+
+func TestPortForwardUpstreamClose(t *testing.T) {
+	// the upstream server answers and closes the connection on its own, without waiting for the VK
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		upstream, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		upstream.Write([]byte("bye"))
+		upstream.Close()
+	}()
+
+	conn, stream := portForward(t, startInterLink(t), listener.Addr().(*net.TCPAddr).Port)
+
+	received, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(received) != "bye" {
+		t.Errorf("expected bye, got %q", received)
+	}
+	expectClosed(t, conn)
+}
+
+func TestPortForwardPortError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	conn, stream := portForward(t, startInterLink(t), port)
+	_, err = io.ReadAll(stream)
+	if err == nil || !strings.Contains(err.Error(), strconv.Itoa(port)) {
+		t.Errorf("expected the dial error of port %d, got %v", port, err)
+	}
+	expectClosed(t, conn)
+}
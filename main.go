@@ -2,15 +2,25 @@ package main
 
 import (
 	"context"
+	"errors"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	logruslogger "github.com/virtual-kubelet/virtual-kubelet/log/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 
 	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
 	"github.com/intertwin-eu/interlink/pkg/interlink/api"
+	"github.com/intertwin-eu/interlink/pkg/interlink/auth"
 )
 
 func main() {
@@ -36,41 +46,193 @@ func main() {
 
 	log.G(ctx).Info(interLinkConfig)
 
-	sidecarEndpoint := ""
-	if strings.HasPrefix(interLinkConfig.Sidecarurl, "unix://") {
-		sidecarEndpoint = interLinkConfig.Sidecarurl
-	} else if strings.HasPrefix(interLinkConfig.Sidecarurl, "http://") {
-		sidecarEndpoint = interLinkConfig.Sidecarurl + ":" + interLinkConfig.Sidecarport
+	if interLinkConfig.DataRootFolder != "" {
+		err = api.OpenStatusDB(interLinkConfig.DataRootFolder)
+		if err != nil {
+			log.G(ctx).Fatal(err)
+		}
+		defer func() {
+			// persisted state is flushed to disk on close
+			err := api.CloseStatusDB()
+			if err != nil {
+				log.G(ctx).Error(err)
+			}
+		}()
+		log.G(ctx).Info("Loaded " + strconv.Itoa(len(api.PodStatuses.Statuses)) + " cached Pod statuses from " + interLinkConfig.DataRootFolder)
 	} else {
-		log.G(ctx).Fatal("Sidecar URL should either start per unix:// or http://")
+		log.G(ctx).Warn("DataRootFolder not set: Pod statuses will not persist across restarts")
+	}
+
+	// traceparent headers are forwarded to the sidecars even when InterLink does not export its own spans
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if interLinkConfig.Tracing.Enabled() {
+		shutdown, err := commonIL.InitTracing(ctx, interLinkConfig.Tracing)
+		if err != nil {
+			log.G(ctx).Fatal(err)
+		}
+		defer func() {
+			err := shutdown(context.Background())
+			if err != nil {
+				log.G(ctx).Error("failed to shutdown TracerProvider: " + err.Error())
+			}
+		}()
+		log.G(ctx).Info("InterLink exporting traces to " + interLinkConfig.Tracing.Endpoint)
+	}
+
+	sidecars, err := api.NewSidecarRouter(interLinkConfig)
+	if err != nil {
+		log.G(ctx).Fatal(err)
+	}
+	err = sidecars.LoadAssignments()
+	if err != nil {
+		log.G(ctx).Fatal(err)
 	}
 
 	interLinkAPIs := api.InterLinkHandler{
-		Config:          interLinkConfig,
-		Ctx:             ctx,
-		SidecarEndpoint: sidecarEndpoint,
+		Config:   interLinkConfig,
+		Ctx:      ctx,
+		Sidecars: sidecars,
 	}
 
 	mutex := http.NewServeMux()
-	mutex.HandleFunc("/status", interLinkAPIs.StatusHandler)
-	mutex.HandleFunc("/create", interLinkAPIs.CreateHandler)
-	mutex.HandleFunc("/delete", interLinkAPIs.DeleteHandler)
-	mutex.HandleFunc("/pinglink", interLinkAPIs.Ping)
-	mutex.HandleFunc("/getLogs", interLinkAPIs.GetLogsHandler)
-	mutex.HandleFunc("/updateCache", interLinkAPIs.UpdateCacheHandler)
-
-	interLinkEndpoint := ""
-	if strings.HasPrefix(interLinkConfig.InterlinkAddress, "unix://") {
-		interLinkEndpoint = interLinkConfig.InterlinkAddress
-	} else if strings.HasPrefix(interLinkConfig.Sidecarurl, "http://") {
-		interLinkEndpoint = interLinkConfig.InterlinkAddress + ":" + interLinkConfig.Interlinkport
+	interLinkAPIs.RegisterRoutes(mutex)
+
+	// metrics are served on their own plain HTTP listener when MetricsAddress is set, so that Prometheus can scrape them without InterLink credentials
+	var metricsServer *http.Server
+	if interLinkConfig.MetricsAddress != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle(api.MetricsRoute, api.MetricsHandler())
+		metricsServer = &http.Server{
+			Addr:              interLinkConfig.MetricsAddress,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			log.G(ctx).Info("InterLink serving metrics on " + interLinkConfig.MetricsAddress + api.MetricsRoute)
+			err := metricsServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.G(ctx).Fatal(err)
+			}
+		}()
+	} else {
+		mutex.Handle(api.MetricsRoute, api.MetricsHandler())
+	}
+
+	// the drain is controlled on its own plain HTTP listener, e.g. bound to localhost, so that no VK credential can stop InterLink from accepting Pods.
+	// Without AdminAddress, InterLink only drains on shutdown.
+	var adminServer *http.Server
+	if interLinkConfig.AdminAddress != "" {
+		adminMux := http.NewServeMux()
+		interLinkAPIs.RegisterAdminRoutes(adminMux)
+		adminServer = &http.Server{
+			Addr:              interLinkConfig.AdminAddress,
+			Handler:           adminMux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			log.G(ctx).Info("InterLink serving the admin API on " + interLinkConfig.AdminAddress)
+			err := adminServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.G(ctx).Fatal(err)
+			}
+		}()
+	}
+
+	var handler http.Handler = mutex
+	if interLinkConfig.OIDC.Enabled() {
+		validator, err := auth.NewValidator(ctx, interLinkConfig.OIDC, nil)
+		if err != nil {
+			log.G(ctx).Fatal(err)
+		}
+		handler = validator.Middleware(mutex)
+		log.G(ctx).Info("InterLink: validating bearer tokens")
+	}
+
+	var listener net.Listener
+	if commonIL.IsUnixSocket(interLinkConfig.InterlinkAddress) {
+		socketMode, err := commonIL.ParseSocketMode(interLinkConfig.SocketMode)
+		if err != nil {
+			log.G(ctx).Fatal(err)
+		}
+		socketPath := commonIL.UnixSocketPath(interLinkConfig.InterlinkAddress)
+		listener, err = commonIL.ListenUnixSocket(socketPath, socketMode, interLinkConfig.SocketOwner, interLinkConfig.SocketGroup)
+		if err != nil {
+			log.G(ctx).Fatal(err)
+		}
+		defer os.Remove(socketPath)
+	} else if interLinkConfig.InterlinkAddress == "" || strings.HasPrefix(interLinkConfig.InterlinkAddress, "http://") || strings.HasPrefix(interLinkConfig.InterlinkAddress, "https://") {
+		if strings.HasPrefix(interLinkConfig.InterlinkAddress, "https://") && !interLinkConfig.TLS.ServerEnabled() {
+			log.G(ctx).Fatal("InterLink URL starts per https:// but no TLS certificate has been configured")
+		}
+		interLinkEndpoint := strings.TrimPrefix(strings.TrimPrefix(interLinkConfig.InterlinkAddress, "http://"), "https://") + ":" + interLinkConfig.Interlinkport
+		listener, err = net.Listen("tcp", interLinkEndpoint)
+		if err != nil {
+			log.G(ctx).Fatal(err)
+		}
 	} else {
-		log.G(ctx).Fatal("Sidecar URL should either start per unix:// or http://")
+		log.G(ctx).Fatal("InterLink URL should either start per unix://, http:// or https://")
 	}
 
-	err = http.ListenAndServe(interLinkEndpoint, mutex)
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if interLinkConfig.TLS.ServerEnabled() {
+		server.TLSConfig, err = interLinkConfig.TLS.ServerTLSConfig()
+		if err != nil {
+			log.G(ctx).Fatal(err)
+		}
+	}
 
+	shutdownTimeout, err := interLinkConfig.ParseShutdownTimeout()
 	if err != nil {
 		log.G(ctx).Fatal(err)
 	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if interLinkConfig.TLS.ServerEnabled() {
+			log.G(ctx).Info("InterLink listening with TLS on " + listener.Addr().String())
+			// Certificates are already loaded in TLSConfig
+			serveErr <- server.ServeTLS(listener, "", "")
+		} else {
+			log.G(ctx).Info("InterLink listening on " + listener.Addr().String())
+			serveErr <- server.Serve(listener)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	select {
+	case err = <-serveErr:
+		log.G(ctx).Fatal(err)
+	case sig := <-signals:
+		log.G(ctx).Info("InterLink: received " + sig.String() + ", shutting down within " + shutdownTimeout.String())
+	}
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	// new Pods are refused, while status and logs are still served until in-flight create and delete calls are over
+	interLinkAPIs.Drain()
+	err = interLinkAPIs.WaitInFlight(shutdownCtx)
+	if err != nil {
+		log.G(ctx).Warn("InterLink: in-flight calls still running at the shutdown deadline")
+	}
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		// e.g. followed logs still streaming
+		log.G(ctx).Warn("InterLink: closing the remaining connections: " + err.Error())
+		server.Close()
+	}
+	if metricsServer != nil {
+		metricsServer.Close()
+	}
+	if adminServer != nil {
+		adminServer.Close()
+	}
+	log.G(ctx).Info("InterLink: shutdown completed")
 }
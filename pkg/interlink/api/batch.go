@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/containerd/log"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// BatchCreateHandler creates many Pods in a single call. Pods are grouped per sidecar and every group is forwarded
+// as a single sidecar /create request. The response lists a PodCreateResult per Pod, in the request order,
+// so that partial failures are reported per Pod: the status code is 200 unless the request itself is invalid.
+func (h *InterLinkHandler) BatchCreateHandler(w http.ResponseWriter, r *http.Request) {
+	log.G(h.Ctx).Info("InterLink: received batch Create call")
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "unable to read the batch Create request: "+err.Error())
+		return
+	}
+
+	var pods []commonIL.PodCreateRequests
+	err = json.Unmarshal(bodyBytes, &pods)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid batch Create request: "+err.Error())
+		return
+	}
+
+	results := make([]commonIL.PodCreateResult, len(pods))
+	// indexes of the Pods forwarded to every sidecar, to map the sidecar results back to the request order
+	podsPerSidecar := make(map[*Sidecar][]int)
+	for i, pod := range pods {
+		results[i] = commonIL.PodCreateResult{PodName: pod.Pod.Name, PodUID: string(pod.Pod.UID), PodNamespace: pod.Pod.Namespace}
+
+		sidecar, err := h.Sidecars.Route(&pod.Pod)
+		if err != nil {
+			results[i].Error = &commonIL.Error{Code: http.StatusBadRequest, Message: err.Error()}
+			continue
+		}
+		podsPerSidecar[sidecar] = append(podsPerSidecar[sidecar], i)
+	}
+
+	for sidecar, indexes := range podsPerSidecar {
+		var retrievedData []commonIL.RetrievedPodData
+		var forwarded []int
+		for _, i := range indexes {
+			data, err := h.sidecarPodData(pods[i])
+			if err != nil {
+				results[i].Error = &commonIL.Error{Code: http.StatusInternalServerError, Message: err.Error()}
+				continue
+			}
+			retrievedData = append(retrievedData, data)
+			forwarded = append(forwarded, i)
+		}
+		if len(forwarded) == 0 {
+			continue
+		}
+
+		h.forwardBatch(r, sidecar, retrievedData, forwarded, results)
+	}
+
+	returnValue, err := json.Marshal(results)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(returnValue)
+}
+
+// forwardBatch sends the Pods to the sidecar in a single /create request and fills their results.
+// If the sidecar answers with a list of PodCreateResult, every Pod gets its own outcome,
+// otherwise the status code of the sidecar applies to the whole batch.
+func (h *InterLinkHandler) forwardBatch(r *http.Request, sidecar *Sidecar, retrievedData []commonIL.RetrievedPodData, forwarded []int, results []commonIL.PodCreateResult) {
+	fail := func(apiErr *commonIL.Error) {
+		log.G(h.Ctx).Error(apiErr)
+		for _, i := range forwarded {
+			results[i].Error = apiErr
+		}
+	}
+
+	bodyBytes, err := json.Marshal(retrievedData)
+	if err != nil {
+		fail(&commonIL.Error{Code: http.StatusInternalServerError, Message: "unable to marshal the Create request for the sidecar: " + err.Error()})
+		return
+	}
+
+	req, err := http.NewRequestWithContext(sidecarContext(r), http.MethodPost, sidecar.Endpoint+"/create", bytes.NewReader(bodyBytes))
+	if err != nil {
+		fail(&commonIL.Error{Code: http.StatusInternalServerError, Message: err.Error()})
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	log.G(h.Ctx).Info("InterLink: forwarding Create call for " + strconv.Itoa(len(forwarded)) + " Pods to sidecar " + sidecar.Name)
+	resp, err := sidecar.Client.Do(req)
+	if err != nil {
+		fail(sidecarUnreachableError(sidecar, err))
+		return
+	}
+	defer resp.Body.Close()
+
+	returnValue, _ := io.ReadAll(resp.Body)
+	log.G(h.Ctx).Debug(string(returnValue))
+
+	var sidecarResults []commonIL.PodCreateResult
+	if json.Unmarshal(returnValue, &sidecarResults) == nil && len(sidecarResults) > 0 {
+		perUID := make(map[string]commonIL.PodCreateResult)
+		for _, result := range sidecarResults {
+			perUID[result.PodUID] = result
+		}
+		for _, i := range forwarded {
+			result, ok := perUID[results[i].PodUID]
+			switch {
+			case !ok && resp.StatusCode != http.StatusOK:
+				// the Pod may have been created anyway: the outcome is unknown, not a failure of the sidecar
+				results[i].Error = &commonIL.Error{Code: http.StatusBadGateway, Message: "sidecar " + sidecar.Name + " did not report the outcome of the Pod", Sidecar: sidecar.Name}
+			case ok && result.Error != nil:
+				result.Error.Sidecar = sidecar.Name
+				results[i].Error = result.Error
+			}
+		}
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		code := http.StatusInternalServerError
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+			code = resp.StatusCode
+		}
+		fail(&commonIL.Error{
+			Code:           code,
+			Message:        "sidecar " + sidecar.Name + " failed to create Pods with status code " + strconv.Itoa(resp.StatusCode),
+			Sidecar:        sidecar.Name,
+			SidecarMessage: strings.TrimSpace(string(returnValue)),
+		})
+	}
+}
@@ -3,25 +3,37 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/containerd/containerd/log"
 
 	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
 )
 
+// sidecarPodData builds the data of the Pod sent to the sidecar by both the single and the batch creations.
+// The ConfigMaps, Secrets and volumes of the containers, MountedVolumes included, are only added when ExportPodData is set:
+// otherwise the sidecar gets the Pod alone, and no content of ConfigMaps or Secrets leaves InterLink.
+func (h *InterLinkHandler) sidecarPodData(pod commonIL.PodCreateRequests) (commonIL.RetrievedPodData, error) {
+	if !h.Config.ExportPodData {
+		return commonIL.RetrievedPodData{Pod: pod.Pod}, nil
+	}
+	data, err := getData(h.Ctx, h.Config, pod)
+	if err != nil {
+		return data, fmt.Errorf("unable to retrieve the data of Pod %s/%s: %w", pod.Pod.Namespace, pod.Pod.Name, err)
+	}
+	return data, nil
+}
+
 // CreateHandler collects and rearranges all needed ConfigMaps/Secrets/EmptyDirs to ship them to the sidecar, then sends a response to the client
 func (h *InterLinkHandler) CreateHandler(w http.ResponseWriter, r *http.Request) {
 	log.G(h.Ctx).Info("InterLink: received Create call")
 
-	statusCode := -1
-
 	bodyBytes, err := io.ReadAll(r.Body)
 	if err != nil {
-		statusCode = http.StatusInternalServerError
-		w.WriteHeader(statusCode)
-		log.G(h.Ctx).Fatal(err)
+		h.writeError(w, http.StatusBadRequest, "unable to read the Create request: "+err.Error())
 		return
 	}
 
@@ -29,23 +41,22 @@ func (h *InterLinkHandler) CreateHandler(w http.ResponseWriter, r *http.Request)
 	var pod commonIL.PodCreateRequests //request for interlink
 	err = json.Unmarshal(bodyBytes, &pod)
 	if err != nil {
-		statusCode = http.StatusInternalServerError
-		log.G(h.Ctx).Fatal(err)
-		w.WriteHeader(statusCode)
+		h.writeError(w, http.StatusBadRequest, "invalid Create request: "+err.Error())
+		return
+	}
+
+	sidecar, err := h.Sidecars.Route(&pod.Pod)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	var retrievedData []commonIL.RetrievedPodData
 
-	data := commonIL.RetrievedPodData{}
-	if h.Config.ExportPodData {
-		data, err = getData(h.Ctx, h.Config, pod)
-		if err != nil {
-			statusCode = http.StatusInternalServerError
-			log.G(h.Ctx).Fatal(err)
-			w.WriteHeader(statusCode)
-			return
-		}
+	data, err := h.sidecarPodData(pod)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	retrievedData = append(retrievedData, data)
@@ -53,46 +64,44 @@ func (h *InterLinkHandler) CreateHandler(w http.ResponseWriter, r *http.Request)
 	if retrievedData != nil {
 		bodyBytes, err = json.Marshal(retrievedData)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			log.G(h.Ctx).Fatal(err)
+			h.writeError(w, http.StatusInternalServerError, "unable to marshal the Create request for the sidecar: "+err.Error())
 			return
 		}
 		log.G(h.Ctx).Debug(string(bodyBytes))
 		reader := bytes.NewReader(bodyBytes)
 
 		log.G(h.Ctx).Info(req)
-		req, err = http.NewRequest(http.MethodPost, h.SidecarEndpoint+"/create", reader)
+		req, err = http.NewRequestWithContext(sidecarContext(r), http.MethodPost, sidecar.Endpoint+"/create", reader)
 
 		if err != nil {
-			statusCode = http.StatusInternalServerError
-			w.WriteHeader(statusCode)
-			log.G(h.Ctx).Fatal(err)
+			h.writeError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		log.G(h.Ctx).Info("InterLink: forwarding Create call to sidecar")
+		log.G(h.Ctx).Info("InterLink: forwarding Create call to sidecar " + sidecar.Name)
 		var resp *http.Response
 
 		req.Header.Set("Content-Type", "application/json")
-		resp, err = http.DefaultClient.Do(req)
+		resp, err = sidecar.Client.Do(req)
 		if err != nil {
-			statusCode = http.StatusInternalServerError
-			w.WriteHeader(statusCode)
-			log.G(h.Ctx).Error(err)
+			h.writeSidecarUnreachable(w, sidecar, err)
 			return
 		}
-
-		if resp.StatusCode == http.StatusOK {
-			statusCode = http.StatusOK
-			log.G(h.Ctx).Debug(statusCode)
-		} else {
-			statusCode = http.StatusInternalServerError
-			log.G(h.Ctx).Error(statusCode)
-		}
+		defer resp.Body.Close()
 
 		returnValue, _ := io.ReadAll(resp.Body)
 		log.G(h.Ctx).Debug(string(returnValue))
-		w.WriteHeader(statusCode)
+		if resp.StatusCode != http.StatusOK {
+			// a Pod refused by the sidecar is reported as such, as the batch creations report it per Pod
+			code := http.StatusInternalServerError
+			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+				code = resp.StatusCode
+			}
+			h.writeSidecarError(w, code, "sidecar "+sidecar.Name+" failed to create Pod "+pod.Pod.Namespace+"/"+pod.Pod.Name+" with status code "+strconv.Itoa(resp.StatusCode), sidecar, returnValue)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
 		w.Write(returnValue)
 	}
 }
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+// testPodRequest returns a Pod mounting the ConfigMap config, with items and a default mode, and the optional Secret missing
+func testPodRequest() commonIL.PodCreateRequests {
+	return commonIL.PodCreateRequests{
+		Pod: v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", UID: "uid"},
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{{
+					Name: "main",
+					VolumeMounts: []v1.VolumeMount{
+						{Name: "config", MountPath: "/etc/config", ReadOnly: true},
+						{Name: "secret", MountPath: "/etc/secret"},
+					},
+				}},
+				Volumes: []v1.Volume{
+					{Name: "config", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{
+						LocalObjectReference: v1.LocalObjectReference{Name: "config"},
+						Items:                []v1.KeyToPath{{Key: "a", Path: "renamed"}, {Key: "b", Path: "b", Mode: int32Ptr(0600)}},
+						DefaultMode:          int32Ptr(0440),
+					}}},
+					{Name: "secret", VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{
+						SecretName: "missing",
+						Optional:   boolPtr(true),
+					}}},
+				},
+			},
+		},
+		ConfigMaps: []v1.ConfigMap{{
+			ObjectMeta: metav1.ObjectMeta{Name: "config"},
+			Data:       map[string]string{"a": "1", "b": "2", "c": "3"},
+		}},
+	}
+}
+
+func TestSidecarPodData(t *testing.T) {
+	t.Run("without ExportPodData only the Pod is sent", func(t *testing.T) {
+		h := &InterLinkHandler{Ctx: context.Background()}
+		data, err := h.sidecarPodData(testPodRequest())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if data.Pod.UID != "uid" {
+			t.Errorf("expected the Pod to be sent, got %+v", data.Pod)
+		}
+		if len(data.Containers) != 0 {
+			t.Errorf("expected no container data, got %+v", data.Containers)
+		}
+	})
+
+	t.Run("with ExportPodData the volumes are mounted honoring items, optional and defaultMode", func(t *testing.T) {
+		h := &InterLinkHandler{Ctx: context.Background(), Config: commonIL.InterLinkConfig{ExportPodData: true}}
+		data, err := h.sidecarPodData(testPodRequest())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(data.Containers) != 1 {
+			t.Fatalf("expected the data of one container, got %d", len(data.Containers))
+		}
+		want := []commonIL.MountedVolume{
+			{
+				Name:      "config",
+				MountPath: "/etc/config",
+				ReadOnly:  true,
+				Files: []commonIL.VolumeFile{
+					{Path: "renamed", Content: []byte("1"), Mode: 0440},
+					{Path: "b", Content: []byte("2"), Mode: 0600},
+				},
+			},
+			{Name: "secret", MountPath: "/etc/secret"},
+		}
+		if got := data.Containers[0].MountedVolumes; !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	})
+
+	t.Run("a missing required source fails", func(t *testing.T) {
+		h := &InterLinkHandler{Ctx: context.Background(), Config: commonIL.InterLinkConfig{ExportPodData: true}}
+		pod := testPodRequest()
+		pod.Pod.Spec.Volumes[1].Secret.Optional = nil
+		if _, err := h.sidecarPodData(pod); err == nil {
+			t.Fatal("expected an error for the missing Secret")
+		}
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/containerd/containerd/log"
 	v1 "k8s.io/api/core/v1"
@@ -12,67 +13,73 @@ import (
 	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
 )
 
-// DeleteHandler deletes the cached status for the provided Pod and forwards the request to the sidecar
+// DeleteHandler forwards the request to the sidecar of the provided Pod and, once deleted there, drops its cached status and routing
 func (h *InterLinkHandler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	log.G(h.Ctx).Info("InterLink: received Delete call")
 
 	bodyBytes, err := io.ReadAll(r.Body)
-	statusCode := http.StatusOK
-
 	if err != nil {
-		statusCode = http.StatusInternalServerError
-		w.WriteHeader(statusCode)
-		log.G(h.Ctx).Fatal(err)
+		h.writeError(w, http.StatusBadRequest, "unable to read the Delete request: "+err.Error())
+		return
 	}
 
-	var req *http.Request
 	var pod *v1.Pod
-	reader := bytes.NewReader(bodyBytes)
 	err = json.Unmarshal(bodyBytes, &pod)
 
 	if err != nil {
-		statusCode = http.StatusInternalServerError
-		w.WriteHeader(statusCode)
-		log.G(h.Ctx).Fatal(err)
+		h.writeError(w, http.StatusBadRequest, "invalid Delete request: "+err.Error())
+		return
 	}
-
-	deleteCachedStatus(string(pod.UID))
-	req, err = http.NewRequest(http.MethodPost, h.SidecarEndpoint+"/delete", reader)
-	if err != nil {
-		statusCode = http.StatusInternalServerError
-		w.WriteHeader(statusCode)
-		log.G(h.Ctx).Error(err)
+	if pod == nil {
+		h.writeError(w, http.StatusBadRequest, "invalid Delete request: missing Pod")
 		return
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	log.G(h.Ctx).Info("InterLink: forwarding Delete call to sidecar")
-	resp, err := http.DefaultClient.Do(req)
+	sidecar := h.Sidecars.ForPod(string(pod.UID))
+	log.G(h.Ctx).Info("InterLink: forwarding Delete call to sidecar " + sidecar.Name)
+	resp, err := forwardDelete(r, sidecar, http.MethodDelete, bodyBytes)
+	if err == nil && resp.StatusCode == http.StatusMethodNotAllowed {
+		// sidecars written for the legacy protocol only accept POST
+		resp.Body.Close()
+		resp, err = forwardDelete(r, sidecar, http.MethodPost, bodyBytes)
+	}
 	if err != nil {
-		statusCode = http.StatusInternalServerError
-		w.WriteHeader(statusCode)
-		log.G(h.Ctx).Error(err)
+		h.writeSidecarUnreachable(w, sidecar, err)
 		return
 	}
+	defer resp.Body.Close()
 
 	returnValue, _ := io.ReadAll(resp.Body)
-	statusCode = resp.StatusCode
-
-	if statusCode != http.StatusOK {
-		w.WriteHeader(http.StatusInternalServerError)
-	} else {
-		w.WriteHeader(http.StatusOK)
-	}
 	log.G(h.Ctx).Debug("InterLink: " + string(returnValue))
+	if resp.StatusCode != http.StatusOK {
+		h.writeSidecarError(w, http.StatusInternalServerError, "sidecar "+sidecar.Name+" failed to delete Pod "+pod.Namespace+"/"+pod.Name+" with status code "+strconv.Itoa(resp.StatusCode), sidecar, returnValue)
+		return
+	}
+
+	// the cached status and the routing are kept until the sidecar holding the Pod has deleted it, so that a retry reaches it again
+	deleteCachedStatus(string(pod.UID))
+	h.Sidecars.Forget(string(pod.UID))
+	Operations.checked(string(pod.UID))
+
 	var returnJson []commonIL.PodStatus
 	returnJson = append(returnJson, commonIL.PodStatus{PodName: pod.Name, PodUID: string(pod.UID), PodNamespace: pod.Namespace})
 
 	bodyBytes, err = json.Marshal(returnJson)
 	if err != nil {
-		log.G(h.Ctx).Error(err)
-		w.Write([]byte{})
-	} else {
-		w.Write(bodyBytes)
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(bodyBytes)
 
 }
+
+// forwardDelete sends the Delete call to the sidecar with the given method
+func forwardDelete(r *http.Request, sidecar *Sidecar, method string, bodyBytes []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(sidecarContext(r), method, sidecar.Endpoint+"/delete", bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return sidecar.Client.Do(req)
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/containerd/containerd/log"
+)
+
+// DrainStatus is returned by the drain endpoint
+type DrainStatus struct {
+	Draining bool `json:"draining"`
+	InFlight int  `json:"inFlight"`
+}
+
+// drainer tracks the calls forwarded to the sidecars and, once draining, refuses new Pods
+type drainer struct {
+	mu       sync.Mutex
+	draining bool
+	inFlight int
+	idle     chan struct{}
+}
+
+// begin registers a call in flight. New Pods are refused while draining, while the other calls are always accepted
+func (d *drainer) begin(newPod bool) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if newPod && d.draining {
+		return false
+	}
+	d.inFlight++
+	return true
+}
+
+// end unregisters a call in flight, waking up the waiters when none is left
+func (d *drainer) end() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.inFlight--
+	if d.inFlight == 0 && d.idle != nil {
+		close(d.idle)
+		d.idle = nil
+	}
+}
+
+func (d *drainer) setDraining(draining bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.draining = draining
+}
+
+func (d *drainer) status() DrainStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return DrainStatus{Draining: d.draining, InFlight: d.inFlight}
+}
+
+// wait returns when no call is in flight anymore, or with the ctx error
+func (d *drainer) wait(ctx context.Context) error {
+	d.mu.Lock()
+	if d.inFlight == 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	if d.idle == nil {
+		d.idle = make(chan struct{})
+	}
+	idle := d.idle
+	d.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Drain makes InterLink refuse new Pods, while status, logs and delete calls are still served
+func (h *InterLinkHandler) Drain() {
+	h.drain.setDraining(true)
+	log.G(h.Ctx).Info("InterLink: draining, new Pods will be refused")
+}
+
+// Resume makes InterLink accept new Pods again
+func (h *InterLinkHandler) Resume() {
+	h.drain.setDraining(false)
+	log.G(h.Ctx).Info("InterLink: accepting new Pods again")
+}
+
+// Draining returns true if InterLink refuses new Pods
+func (h *InterLinkHandler) Draining() bool {
+	return h.drain.status().Draining
+}
+
+// WaitInFlight waits for the create and delete calls being forwarded to the sidecars, up to the ctx deadline
+func (h *InterLinkHandler) WaitInFlight(ctx context.Context) error {
+	return h.drain.wait(ctx)
+}
+
+// tracked registers the calls of handler as in flight, so that shutdown waits for them.
+// Calls creating new Pods are refused with 503 while draining.
+func (h *InterLinkHandler) tracked(newPod bool, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.drain.begin(newPod) {
+			w.Header().Set("Retry-After", "60")
+			h.writeError(w, http.StatusServiceUnavailable, "InterLink is draining and does not accept new Pods")
+			return
+		}
+		defer h.drain.end()
+		handler(w, r)
+	}
+}
+
+// DrainHandler reports the drain status on GET, starts draining on POST and resumes accepting new Pods on DELETE.
+// POST and DELETE are only routed on the admin listener, see RegisterAdminRoutes.
+func (h *InterLinkHandler) DrainHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.Drain()
+	case http.MethodDelete:
+		h.Resume()
+	}
+
+	returnValue, err := json.Marshal(h.drain.status())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(returnValue)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/containerd/containerd/log"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// writeError logs the failure and answers with a commonIL.Error JSON body
+func (h *InterLinkHandler) writeError(w http.ResponseWriter, statusCode int, message string) {
+	h.writeJSONError(w, &commonIL.Error{Code: statusCode, Message: message})
+}
+
+// writeSidecarError logs the failure of a sidecar call and answers with a commonIL.Error JSON body, holding the original sidecar response
+func (h *InterLinkHandler) writeSidecarError(w http.ResponseWriter, statusCode int, message string, sidecar *Sidecar, sidecarMessage []byte) {
+	h.writeJSONError(w, &commonIL.Error{
+		Code:           statusCode,
+		Message:        message,
+		Sidecar:        sidecar.Name,
+		SidecarMessage: strings.TrimSpace(string(sidecarMessage)),
+	})
+}
+
+// writeSidecarUnreachable answers a sidecar call that got no response: 503 while its circuit breaker is open, 504 on timeout, 502 otherwise
+func (h *InterLinkHandler) writeSidecarUnreachable(w http.ResponseWriter, sidecar *Sidecar, err error) {
+	h.writeJSONError(w, sidecarUnreachableError(sidecar, err))
+}
+
+// sidecarUnreachableError describes a sidecar call that got no response. Only 503 tells that the call was not sent at all.
+func sidecarUnreachableError(sidecar *Sidecar, err error) *commonIL.Error {
+	statusCode := http.StatusBadGateway
+	var timeoutErr *SidecarTimeoutError
+	if errors.Is(err, ErrCircuitOpen) {
+		statusCode = http.StatusServiceUnavailable
+	} else if errors.As(err, &timeoutErr) {
+		statusCode = http.StatusGatewayTimeout
+	}
+	return &commonIL.Error{Code: statusCode, Message: "unable to reach sidecar " + sidecar.Name + ": " + err.Error(), Sidecar: sidecar.Name}
+}
+
+func (h *InterLinkHandler) writeJSONError(w http.ResponseWriter, apiErr *commonIL.Error) {
+	log.G(h.Ctx).Error(apiErr)
+
+	returnValue, err := json.Marshal(apiErr)
+	if err != nil {
+		log.G(h.Ctx).Error(err)
+		http.Error(w, apiErr.Error(), apiErr.Code)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Code)
+	w.Write(returnValue)
+}
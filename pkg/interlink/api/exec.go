@@ -0,0 +1,143 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/containerd/containerd/log"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// streamUpgrader upgrades the exec, attach and port forward calls of the VK. The VK is not a browser, so the Origin header is not checked.
+var streamUpgrader = websocket.Upgrader{
+	Subprotocols: []string{commonIL.StreamProtocol},
+	CheckOrigin:  func(r *http.Request) bool { return true },
+}
+
+// ExecHandler serves GET /api/v1/pods/{namespace}/{name}/exec, running a command in a container.
+// The call is upgraded to a websocket, relayed to the sidecar /exec route.
+func (h *InterLinkHandler) ExecHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := commonIL.ParseExecRequest(podQuery(r))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.relayStream(w, r, "/exec", req.Namespace+"/"+req.PodName, req.PodUID, req.Query())
+}
+
+// AttachHandler serves GET /api/v1/pods/{namespace}/{name}/attach, attaching to the main process of a container.
+// The call is upgraded to a websocket, relayed to the sidecar /attach route.
+func (h *InterLinkHandler) AttachHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := commonIL.ParseExecRequest(podQuery(r))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.relayStream(w, r, "/attach", req.Namespace+"/"+req.PodName, req.PodUID, req.Query())
+}
+
+// PortForwardHandler serves GET /api/v1/pods/{namespace}/{name}/portforward, forwarding a connection to a port of the Pod.
+// The call is upgraded to a websocket, relayed to the sidecar /portforward route. Every forwarded connection has its own stream.
+func (h *InterLinkHandler) PortForwardHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := commonIL.ParsePortForwardRequest(podQuery(r))
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.relayStream(w, r, "/portforward", req.Namespace+"/"+req.PodName, req.PodUID, req.Query())
+}
+
+// podQuery returns the query parameters of a stream call, along with the namespace and the name of the Pod read from the path
+func podQuery(r *http.Request) url.Values {
+	query := r.URL.Query()
+	query.Set("namespace", r.PathValue("namespace"))
+	query.Set("name", r.PathValue("name"))
+	return query
+}
+
+// relayStream opens the stream to the sidecar of the Pod first, so that its failures are answered as regular errors,
+// then upgrades the call of the VK and relays the messages both ways until either side closes the stream
+func (h *InterLinkHandler) relayStream(w http.ResponseWriter, r *http.Request, route string, pod string, uid string, query url.Values) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		h.writeError(w, http.StatusBadRequest, route+" streams need a websocket upgrade")
+		return
+	}
+
+	sidecar := h.Sidecars.ForPod(uid)
+	header := http.Header{}
+	otel.GetTextMapPropagator().Inject(r.Context(), propagation.HeaderCarrier(header))
+
+	log.G(h.Ctx).Info("InterLink: opening " + route + " stream to sidecar " + sidecar.Name + " for Pod " + pod)
+	sidecarConn, resp, err := sidecar.dialer.DialContext(r.Context(), commonIL.WebsocketURL(sidecar.Endpoint)+route+"?"+query.Encode(), header)
+	if err != nil {
+		if resp == nil {
+			h.writeSidecarUnreachable(w, sidecar, err)
+			return
+		}
+		returnValue, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		statusCode := http.StatusBadGateway
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusNotImplemented {
+			statusCode = http.StatusNotImplemented
+		}
+		h.writeSidecarError(w, statusCode, "sidecar "+sidecar.Name+" refused the "+route+" stream with status code "+resp.Status, sidecar, returnValue)
+		return
+	}
+
+	conn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already answered the VK
+		log.G(h.Ctx).Error(err)
+		sidecarConn.Close()
+		return
+	}
+
+	relayWebsocket(conn, sidecarConn)
+	log.G(h.Ctx).Info("InterLink: " + route + " stream for Pod " + pod + " closed")
+}
+
+// relayWebsocket copies the messages between a and b. Once either side closes, the close reason is forwarded to the other one and both are closed.
+func relayWebsocket(a *websocket.Conn, b *websocket.Conn) {
+	done := make(chan struct{}, 2)
+	relay := func(dst *websocket.Conn, src *websocket.Conn) {
+		defer func() { done <- struct{}{} }()
+		for {
+			messageType, message, err := src.ReadMessage()
+			if err != nil {
+				closeMessage := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+				var closeErr *websocket.CloseError
+				if errors.As(err, &closeErr) {
+					if closeErr.Code != websocket.CloseNoStatusReceived {
+						closeMessage = websocket.FormatCloseMessage(closeErr.Code, closeErr.Text)
+					}
+				} else {
+					closeMessage = websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+					if !errors.Is(err, net.ErrClosed) {
+						log.L.Warning("Stream interrupted: " + err.Error())
+					}
+				}
+				dst.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+				return
+			}
+			err = dst.WriteMessage(messageType, message)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	go relay(a, b)
+	go relay(b, a)
+	<-done
+	a.Close()
+	b.Close()
+	<-done
+}
@@ -2,10 +2,12 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
 	"sync"
 
 	"github.com/containerd/containerd/log"
+	bolt "go.etcd.io/bbolt"
 	v1 "k8s.io/api/core/v1"
 
 	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
@@ -14,11 +16,12 @@ import (
 type MutexStatuses struct {
 	mu       sync.Mutex
 	Statuses map[string]commonIL.PodStatus
+	db       *bolt.DB
 }
 
 var PodStatuses MutexStatuses
 
-// getData retrieves ConfigMaps, Secrets and EmptyDirs from the provided pod by calling the retrieveData function.
+// getData retrieves ConfigMaps, Secrets, EmptyDirs and projected volumes from the provided pod by calling the retrieveData function.
 // The config is needed by the retrieveData function.
 // The function aggregates the return values of retrieveData function in a commonIL.RetrievedPodData variable and returns it, along with the first encountered error.
 func getData(ctx context.Context, config commonIL.InterLinkConfig, pod commonIL.PodCreateRequests) (commonIL.RetrievedPodData, error) {
@@ -51,11 +54,16 @@ func getData(ctx context.Context, config commonIL.InterLinkConfig, pod commonIL.
 	return retrievedData, nil
 }
 
-// retrieveData retrieves ConfigMaps, Secrets and EmptyDirs.
+// retrieveData retrieves ConfigMaps, Secrets, EmptyDirs, the projected, downwardAPI and mapped volumes prepared by the VK and the resolved environment.
+// ConfigMap, Secret, projected and downwardAPI volumes are also normalized per mount into MountedVolumes, honoring items, optional and defaultMode.
 // The config is needed to specify the EmptyDirs mounting point.
-// It returns the retrieved data in a variable of type commonIL.RetrievedContainer and the first encountered error.
+// It returns the retrieved data in a variable of type commonIL.RetrievedContainer and the first encountered error, such as a missing required key or source.
 func retrieveData(ctx context.Context, config commonIL.InterLinkConfig, pod commonIL.PodCreateRequests, container v1.Container) (commonIL.RetrievedContainer, error) {
 	retrievedData := commonIL.RetrievedContainer{}
+	if env, ok := pod.Env[container.Name]; ok {
+		retrievedData.Name = container.Name
+		retrievedData.Env = env
+	}
 	for _, mountVar := range container.VolumeMounts {
 		log.G(ctx).Debug("-- Retrieving data for mountpoint " + mountVar.Name)
 
@@ -65,29 +73,70 @@ func retrieveData(ctx context.Context, config commonIL.InterLinkConfig, pod comm
 
 					log.G(ctx).Info("--- Retrieving ConfigMap " + vol.ConfigMap.Name)
 					retrievedData.Name = container.Name
+					var files []commonIL.VolumeFile
+					found := false
 					for _, cfgMap := range pod.ConfigMaps {
-						if cfgMap.Name == vol.ConfigMap.Name {
-							retrievedData.Name = container.Name
+						if cfgMap.Name == vol.ConfigMap.Name && !found {
+							found = true
 							retrievedData.ConfigMaps = append(retrievedData.ConfigMaps, cfgMap)
+							var err error
+							files, err = commonIL.KeysToFiles("ConfigMap "+cfgMap.Name, commonIL.ConfigMapData(cfgMap), vol.ConfigMap.Items, isOptional(vol.ConfigMap.Optional), commonIL.VolumeMode(vol.ConfigMap.DefaultMode))
+							if err != nil {
+								return retrievedData, fmt.Errorf("volume %s: %w", vol.Name, err)
+							}
 						}
 					}
+					if !found && !isOptional(vol.ConfigMap.Optional) {
+						return retrievedData, fmt.Errorf("volume %s: ConfigMap %s not found in the request", vol.Name, vol.ConfigMap.Name)
+					}
+					retrievedData.MountedVolumes = append(retrievedData.MountedVolumes, mountedVolume(mountVar, files))
 
 				} else if vol.Secret != nil {
 
 					log.G(ctx).Info("--- Retrieving Secret " + vol.Secret.SecretName)
 					retrievedData.Name = container.Name
+					var files []commonIL.VolumeFile
+					found := false
 					for _, secret := range pod.Secrets {
-						if secret.Name == vol.Secret.SecretName {
-							retrievedData.Name = container.Name
+						if secret.Name == vol.Secret.SecretName && !found {
+							found = true
 							retrievedData.Secrets = append(retrievedData.Secrets, secret)
+							var err error
+							files, err = commonIL.KeysToFiles("Secret "+secret.Name, commonIL.SecretData(secret), vol.Secret.Items, isOptional(vol.Secret.Optional), commonIL.VolumeMode(vol.Secret.DefaultMode))
+							if err != nil {
+								return retrievedData, fmt.Errorf("volume %s: %w", vol.Name, err)
+							}
 						}
 					}
+					if !found && !isOptional(vol.Secret.Optional) {
+						return retrievedData, fmt.Errorf("volume %s: Secret %s not found in the request", vol.Name, vol.Secret.SecretName)
+					}
+					retrievedData.MountedVolumes = append(retrievedData.MountedVolumes, mountedVolume(mountVar, files))
 
 				} else if vol.EmptyDir != nil {
 					edPath := filepath.Join(config.DataRootFolder, pod.Pod.Namespace+"-"+string(pod.Pod.UID)+"/"+"emptyDirs/"+vol.Name)
 
 					retrievedData.Name = container.Name
 					retrievedData.EmptyDirs = append(retrievedData.EmptyDirs, edPath)
+				} else if vol.Projected != nil || vol.DownwardAPI != nil {
+
+					log.G(ctx).Info("--- Retrieving projected volume " + vol.Name)
+					retrievedData.Name = container.Name
+					for _, projected := range pod.ProjectedVolumes {
+						if projected.Name == vol.Name {
+							retrievedData.ProjectedVolumes = append(retrievedData.ProjectedVolumes, projected)
+							retrievedData.MountedVolumes = append(retrievedData.MountedVolumes, mountedVolume(mountVar, projected.Files))
+						}
+					}
+				} else if vol.PersistentVolumeClaim != nil || vol.HostPath != nil {
+
+					for _, mapped := range pod.MappedVolumes {
+						if mapped.Name == vol.Name {
+							log.G(ctx).Info("--- Retrieving mapping of volume " + vol.Name)
+							retrievedData.Name = container.Name
+							retrievedData.MappedVolumes = append(retrievedData.MappedVolumes, mapped)
+						}
+					}
 				}
 			}
 		}
@@ -95,10 +144,29 @@ func retrieveData(ctx context.Context, config commonIL.InterLinkConfig, pod comm
 	return retrievedData, nil
 }
 
-// deleteCachedStatus locks the map PodStatuses and delete the uid key from that map
+// mountedVolume returns the normalized files of a volume at the given mount of a container
+func mountedVolume(mount v1.VolumeMount, files []commonIL.VolumeFile) commonIL.MountedVolume {
+	return commonIL.MountedVolume{
+		Name:      mount.Name,
+		MountPath: mount.MountPath,
+		SubPath:   mount.SubPath,
+		ReadOnly:  mount.ReadOnly,
+		Files:     files,
+	}
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
+
+// deleteCachedStatus locks the map PodStatuses and delete the uid key from that map and from the on-disk store
 func deleteCachedStatus(uid string) {
 	PodStatuses.mu.Lock()
 	delete(PodStatuses.Statuses, uid)
+	err := forgetStatus(uid)
+	if err != nil {
+		log.L.Error(err)
+	}
 	PodStatuses.mu.Unlock()
 }
 
@@ -113,7 +181,7 @@ func checkIfCached(uid string) bool {
 	}
 }
 
-// updateStatuses locks and updates the PodStatuses map with the statuses contained in the returnedStatuses slice
+// updateStatuses locks and updates the PodStatuses map and the on-disk store with the statuses contained in the returnedStatuses slice
 func updateStatuses(returnedStatuses []commonIL.PodStatus) {
 	PodStatuses.mu.Lock()
 
@@ -122,5 +190,10 @@ func updateStatuses(returnedStatuses []commonIL.PodStatus) {
 		PodStatuses.Statuses[new.PodUID] = new
 	}
 
+	err := persistStatuses(returnedStatuses)
+	if err != nil {
+		log.L.Error(err)
+	}
+
 	PodStatuses.mu.Unlock()
 }
@@ -7,8 +7,9 @@ import (
 )
 
 type InterLinkHandler struct {
-	Config          interlink.InterLinkConfig
-	Ctx             context.Context
-	SidecarEndpoint string
-	// TODO: http client with TLS
+	Config   interlink.InterLinkConfig
+	Ctx      context.Context
+	Sidecars *SidecarRouter
+
+	drain drainer
 }
@@ -3,7 +3,6 @@ package api
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"io"
 	"net/http"
 	"strconv"
@@ -14,33 +13,28 @@ import (
 )
 
 func (h *InterLinkHandler) GetLogsHandler(w http.ResponseWriter, r *http.Request) {
-	statusCode := http.StatusOK
 	log.G(h.Ctx).Info("InterLink: received GetLogs call")
 	bodyBytes, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.G(h.Ctx).Fatal(err)
+		h.writeError(w, http.StatusBadRequest, "unable to read the GetLogs request: "+err.Error())
+		return
 	}
 
 	log.G(h.Ctx).Info("InterLink: unmarshal GetLogs request")
 	var req2 commonIL.LogStruct //incoming request. To be used in interlink API. req is directly forwarded to sidecar
 	err = json.Unmarshal(bodyBytes, &req2)
 	if err != nil {
-		statusCode = http.StatusInternalServerError
-		w.WriteHeader(statusCode)
-		log.G(h.Ctx).Error(err)
+		h.writeError(w, http.StatusBadRequest, "invalid GetLogs request: "+err.Error())
 		return
 	}
 
 	log.G(h.Ctx).Info("InterLink: new GetLogs podUID: now ", string(req2.PodUID))
-	if (req2.Opts.Tail != 0 && req2.Opts.LimitBytes != 0) || (req2.Opts.SinceSeconds != 0 && !req2.Opts.SinceTime.IsZero()) {
-		statusCode = http.StatusInternalServerError
-		w.WriteHeader(statusCode)
-		if req2.Opts.Tail != 0 && req2.Opts.LimitBytes != 0 {
-			w.Write([]byte("Both Tail and LimitBytes set. Set only one of them"))
-		} else {
-			w.Write([]byte("Both SinceSeconds and SinceTime set. Set only one of them"))
-		}
-		log.G(h.Ctx).Error(errors.New("check opts configurations"))
+	if req2.Opts.Tail != 0 && req2.Opts.LimitBytes != 0 {
+		h.writeError(w, http.StatusBadRequest, "Both Tail and LimitBytes set. Set only one of them")
+		return
+	}
+	if req2.Opts.SinceSeconds != 0 && !req2.Opts.SinceTime.IsZero() {
+		h.writeError(w, http.StatusBadRequest, "Both SinceSeconds and SinceTime set. Set only one of them")
 		return
 	}
 
@@ -48,35 +42,40 @@ func (h *InterLinkHandler) GetLogsHandler(w http.ResponseWriter, r *http.Request
 
 	bodyBytes, err = json.Marshal(req2)
 	if err != nil {
-		statusCode = http.StatusInternalServerError
-		w.WriteHeader(statusCode)
-		log.G(h.Ctx).Error(err)
+		h.writeError(w, http.StatusInternalServerError, "unable to marshal the GetLogs request for the sidecar: "+err.Error())
 		return
 	}
 	reader := bytes.NewReader(bodyBytes)
-	req, err := http.NewRequest(http.MethodGet, h.SidecarEndpoint+"/getLogs", reader)
+	sidecar := h.Sidecars.ForPod(req2.PodUID)
+	// the sidecar call is bound to the client request, so that it is cancelled when the client disconnects (e.g. kubectl logs -f interrupted)
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, sidecar.Endpoint+"/getLogs", reader)
 	if err != nil {
-		log.G(h.Ctx).Fatal(err)
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	log.G(h.Ctx).Info("InterLink: forwarding GetLogs call to sidecar")
-	resp, err := http.DefaultClient.Do(req)
+	log.G(h.Ctx).Info("InterLink: forwarding GetLogs call to sidecar " + sidecar.Name)
+	resp, err := sidecar.Client.Do(req)
 	if err != nil {
-		statusCode = http.StatusInternalServerError
-		w.WriteHeader(statusCode)
-		log.G(h.Ctx).Error(err)
+		h.writeSidecarUnreachable(w, sidecar, err)
 		return
 	}
 
+	defer resp.Body.Close()
+
 	if resp.StatusCode != http.StatusOK {
-		log.L.Error("Unexpected error occured. Status code: " + strconv.Itoa(resp.StatusCode) + ". Check Sidecar's logs for further informations")
-		statusCode = http.StatusInternalServerError
+		sidecarMessage, _ := io.ReadAll(resp.Body)
+		h.writeSidecarError(w, http.StatusInternalServerError, "sidecar "+sidecar.Name+" failed to get the logs of Pod "+req2.Namespace+"/"+req2.PodName+" with status code "+strconv.Itoa(resp.StatusCode), sidecar, sidecarMessage)
+		return
 	}
 
-	returnValue, _ := io.ReadAll(resp.Body)
-	log.G(h.Ctx).Debug("InterLink: logs " + string(returnValue))
-
-	w.WriteHeader(statusCode)
-	w.Write(returnValue)
+	// logs are proxied as they come, without buffering, so that followed logs are streamed to the client
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	err = commonIL.StreamResponse(w, resp.Body)
+	if err != nil && r.Context().Err() == nil {
+		log.G(h.Ctx).Error(err)
+	}
+	log.G(h.Ctx).Debug("InterLink: GetLogs stream for pod " + req2.PodUID + " closed")
 }
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	v1 "k8s.io/api/core/v1"
+)
+
+// MetricsRoute is the route Prometheus metrics are served on
+const MetricsRoute = "/metrics"
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "interlink",
+		Name:      "requests_total",
+		Help:      "Requests served by the InterLink API, per handler and status code.",
+	}, []string{"handler", "code"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "interlink",
+		Name:      "request_duration_seconds",
+		Help:      "Time spent serving InterLink API requests, per handler. Followed logs last as long as the stream.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"handler"})
+
+	sidecarRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "interlink",
+		Name:      "sidecar_request_duration_seconds",
+		Help:      "Time taken by sidecars to answer, up to the response headers, per sidecar and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"sidecar", "route"})
+
+	sidecarErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "interlink",
+		Name:      "sidecar_errors_total",
+		Help:      "Sidecar calls failed, either unreachable or answering with an error status code, per sidecar and route.",
+	}, []string{"sidecar", "route"})
+
+	cachedStatuses = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "interlink",
+		Name:      "cached_pod_statuses",
+		Help:      "Pod statuses held in the InterLink cache.",
+	}, func() float64 {
+		PodStatuses.mu.Lock()
+		defer PodStatuses.mu.Unlock()
+		return float64(len(PodStatuses.Statuses))
+	})
+
+	podsPerPhaseDesc = prometheus.NewDesc("interlink_pods", "Pods in the InterLink cache, per phase derived from their container states.", []string{"phase"}, nil)
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal, requestDuration, sidecarRequestDuration, sidecarErrorsTotal, cachedStatuses, podPhaseCollector{})
+}
+
+// MetricsHandler serves the Prometheus metrics
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// instrument records the count and the latency of the requests served by handler, labelled with name, and traces them
+func instrument(name string, handler http.HandlerFunc) http.Handler {
+	labels := prometheus.Labels{"handler": name}
+	return promhttp.InstrumentHandlerCounter(requestsTotal.MustCurryWith(labels),
+		promhttp.InstrumentHandlerDuration(requestDuration.MustCurryWith(labels), traced(name, handler)))
+}
+
+// sidecarTransport records the latency and the failures of the calls to a sidecar
+type sidecarTransport struct {
+	sidecar string
+	next    http.RoundTripper
+}
+
+func (t *sidecarTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	sidecarRequestDuration.WithLabelValues(t.sidecar, req.URL.Path).Observe(time.Since(start).Seconds())
+	if err != nil || resp.StatusCode >= http.StatusBadRequest {
+		sidecarErrorsTotal.WithLabelValues(t.sidecar, req.URL.Path).Inc()
+	}
+	return resp, err
+}
+
+// podPhaseCollector counts the cached Pods per phase at every scrape, so that Pods dropped from the cache disappear from the counts
+type podPhaseCollector struct{}
+
+func (podPhaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- podsPerPhaseDesc
+}
+
+func (podPhaseCollector) Collect(ch chan<- prometheus.Metric) {
+	counts := map[v1.PodPhase]int{
+		v1.PodPending:   0,
+		v1.PodRunning:   0,
+		v1.PodSucceeded: 0,
+		v1.PodFailed:    0,
+	}
+
+	PodStatuses.mu.Lock()
+	for _, status := range PodStatuses.Statuses {
+		counts[podPhase(status.Containers)]++
+	}
+	PodStatuses.mu.Unlock()
+
+	for phase, count := range counts {
+		ch <- prometheus.MustNewConstMetric(podsPerPhaseDesc, prometheus.GaugeValue, float64(count), string(phase))
+	}
+}
+
+// podPhase derives the phase of a Pod from its container states, the same way the VK does:
+// Running if a container runs or is setting up, Failed if a container exited with an error, Succeeded once every container terminated
+func podPhase(containers []v1.ContainerStatus) v1.PodPhase {
+	if len(containers) == 0 {
+		return v1.PodPending
+	}
+
+	terminated := 0
+	failed := false
+	for _, container := range containers {
+		switch {
+		case container.State.Running != nil || container.State.Waiting != nil:
+			return v1.PodRunning
+		case container.State.Terminated != nil:
+			terminated++
+			if container.State.Terminated.ExitCode != 0 {
+				failed = true
+			}
+		}
+	}
+
+	switch {
+	case failed:
+		return v1.PodFailed
+	case terminated == len(containers):
+		return v1.PodSucceeded
+	default:
+		return v1.PodPending
+	}
+}
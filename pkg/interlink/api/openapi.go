@@ -0,0 +1,419 @@
+package api
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+const openAPIVersion = "3.0.3"
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	dateTimeTypes     = map[reflect.Type]bool{
+		reflect.TypeOf(time.Time{}):        true,
+		reflect.TypeOf(metav1.Time{}):      true,
+		reflect.TypeOf(metav1.MicroTime{}): true,
+	}
+	intOrStringType = reflect.TypeOf(intstr.IntOrString{})
+	rawJSONType     = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaGenerator builds OpenAPI schemas from Go types through reflection, following their JSON encoding.
+// Every struct becomes a component, referenced by its package path in reverse domain notation (e.g. io.k8s.api.core.v1.Pod).
+type schemaGenerator struct {
+	schemas map[string]interface{}
+}
+
+// schemaFor returns the schema of t, registering the components needed to describe it
+func (g *schemaGenerator) schemaFor(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case dateTimeTypes[t]:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawJSONType:
+		// any JSON value
+		return map[string]interface{}{}
+	case t == intOrStringType:
+		return map[string]interface{}{"oneOf": []interface{}{
+			map[string]interface{}{"type": "integer"},
+			map[string]interface{}{"type": "string"},
+		}}
+	case t.Kind() == reflect.Struct && (t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType)):
+		// e.g. resource.Quantity, serialized as a string
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, ok := g.schemas[name]; !ok {
+			// register the name before walking the fields, to stop on recursive types
+			g.schemas[name] = map[string]interface{}{}
+			g.schemas[name] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// structSchema describes the JSON object a struct is encoded to, inlining embedded structs
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	g.addProperties(t, properties)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+func (g *schemaGenerator) addProperties(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			g.addProperties(fieldType, properties)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schemaFor(field.Type)
+	}
+}
+
+// schemaName returns the component name of a type, e.g. io.k8s.api.core.v1.Pod
+func schemaName(t reflect.Type) string {
+	segments := strings.Split(t.PkgPath(), "/")
+	domain := strings.Split(segments[0], ".")
+	for i, j := 0, len(domain)-1; i < j; i, j = i+1, j-1 {
+		domain[i], domain[j] = domain[j], domain[i]
+	}
+	return strings.Join(append(append(domain, segments[1:]...), t.Name()), ".")
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+func jsonRequestBody(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"required": true, "content": jsonContent(schema)}
+}
+
+func jsonResponse(description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"200": map[string]interface{}{"description": description, "content": jsonContent(schema)}}
+}
+
+func acceptedResponse(description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"202": map[string]interface{}{"description": description, "content": jsonContent(schema)}}
+}
+
+// streamResponse describes the websocket upgrade of the exec and attach streams. The messages exchanged over the websocket
+// are described in the description, while their JSON payloads, i.e. ExecResult and TermSize, are registered as components.
+func (g *schemaGenerator) streamResponse(description string) map[string]interface{} {
+	g.schemaFor(reflect.TypeOf(commonIL.ExecResult{}))
+	g.schemaFor(reflect.TypeOf(commonIL.TermSize{}))
+	return map[string]interface{}{"101": map[string]interface{}{
+		"description": description + ". The websocket uses the " + commonIL.StreamProtocol + " subprotocol: every binary message starts with its channel, " +
+			"0 for stdin, 1 for stdout, 2 for stderr, 3 for the final ExecResult, 4 for TermSize resize events and 5 to close the channel in the payload",
+	}}
+}
+
+// streamParameters are the query parameters of the exec and attach streams, see commonIL.ExecRequest
+func streamParameters() []interface{} {
+	return []interface{}{
+		queryParameter("uid", "string", "UID of the Pod"),
+		queryParameter("container", "string", "Name of the container"),
+		map[string]interface{}{"name": "command", "in": "query", "description": "Command to run, one parameter per argument",
+			"schema": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}},
+		queryParameter("input", "boolean", "Attach stdin"),
+		queryParameter("output", "boolean", "Attach stdout"),
+		queryParameter("error", "boolean", "Attach stderr"),
+		queryParameter("tty", "boolean", "Allocate a terminal"),
+	}
+}
+
+// portForwardResponse describes the websocket upgrade of the port forward streams, one per forwarded connection
+func portForwardResponse(description string) map[string]interface{} {
+	return map[string]interface{}{"101": map[string]interface{}{
+		"description": description + ". The websocket uses the " + commonIL.StreamProtocol + " subprotocol: every binary message starts with its channel, " +
+			"0 for the bytes of the connection, both ways, 3 for the error interrupting the connection, as plain text, and 5 to half-close the connection",
+	}}
+}
+
+func textResponse(description string) map[string]interface{} {
+	return map[string]interface{}{"200": map[string]interface{}{
+		"description": description,
+		"content":     map[string]interface{}{"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}},
+	}}
+}
+
+func operation(summary string, responses map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"summary": summary, "responses": responses}
+}
+
+func pathParameter(name string) map[string]interface{} {
+	return map[string]interface{}{"name": name, "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}}
+}
+
+func queryParameter(name string, schemaType string, description string) map[string]interface{} {
+	return map[string]interface{}{"name": name, "in": "query", "description": description, "schema": map[string]interface{}{"type": schemaType}}
+}
+
+func document(title string, description string, paths map[string]interface{}, g *schemaGenerator) map[string]interface{} {
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":       title,
+			"description": description,
+			"version":     commonIL.Version,
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": g.schemas},
+	}
+}
+
+// InterLinkOpenAPI returns the OpenAPI document of the InterLink API, generated from the Go types it exchanges.
+// Legacy flat routes are listed as deprecated.
+func InterLinkOpenAPI() map[string]interface{} {
+	g := &schemaGenerator{schemas: map[string]interface{}{}}
+
+	podCreateRequests := g.schemaFor(reflect.TypeOf(commonIL.PodCreateRequests{}))
+	pod := g.schemaFor(reflect.TypeOf(v1.Pod{}))
+	pods := g.schemaFor(reflect.TypeOf([]v1.Pod{}))
+	podStatuses := g.schemaFor(reflect.TypeOf([]commonIL.PodStatus{}))
+	logStruct := g.schemaFor(reflect.TypeOf(commonIL.LogStruct{}))
+	drainStatus := g.schemaFor(reflect.TypeOf(DrainStatus{}))
+	podCreateResults := g.schemaFor(reflect.TypeOf([]commonIL.PodCreateResult{}))
+	asyncOperation := g.schemaFor(reflect.TypeOf(commonIL.Operation{}))
+	podStats := g.schemaFor(reflect.TypeOf([]commonIL.PodStats{}))
+	idempotencyKey := map[string]interface{}{
+		"name": IdempotencyKeyHeader, "in": "header", "schema": map[string]interface{}{"type": "string"},
+		"description": "Key of the submit. Submits for a Pod with a pending, running or succeeded operation return that operation; " +
+			"a failed operation is returned to submits with the same key, while a different key starts a new operation, " +
+			"unless the failed creation has an unknown outcome",
+	}
+
+	withBody := func(op map[string]interface{}, body map[string]interface{}) map[string]interface{} {
+		op["requestBody"] = body
+		return op
+	}
+	deprecated := func(op map[string]interface{}) map[string]interface{} {
+		op["deprecated"] = true
+		return op
+	}
+
+	paths := map[string]interface{}{
+		APIV1Prefix + "/pods": map[string]interface{}{
+			"post": withBody(operation("Create a Pod on its sidecar", textResponse("Response of the sidecar")), jsonRequestBody(podCreateRequests)),
+		},
+		APIV1Prefix + "/pods/batch": map[string]interface{}{
+			"post": withBody(operation("Create many Pods, forwarding them to every sidecar in a single call", jsonResponse("Outcome of every Pod, in the request order", podCreateResults)),
+				jsonRequestBody(g.schemaFor(reflect.TypeOf([]commonIL.PodCreateRequests{})))),
+		},
+		APIV1Prefix + "/pods/{namespace}/{name}": map[string]interface{}{
+			"parameters": []interface{}{pathParameter("namespace"), pathParameter("name")},
+			"delete":     withBody(operation("Delete a Pod from its sidecar", jsonResponse("Deleted Pods", podStatuses)), jsonRequestBody(pod)),
+		},
+		APIV1Prefix + "/pods/status": map[string]interface{}{
+			"get":  operation("List every cached Pod status", jsonResponse("Cached Pod statuses", podStatuses)),
+			"post": withBody(operation("Get the status of the given Pods", jsonResponse("Pod statuses", podStatuses)), jsonRequestBody(pods)),
+		},
+		APIV1Prefix + "/resources": map[string]interface{}{
+			"get": operation("Get the resources of the remote site, summed over every sidecar reporting them",
+				jsonResponse("Resources of the remote site. Sidecars failing are left out. Answered with 501 when no sidecar reports its resources, and with 502 when none does and some failed", g.schemaFor(reflect.TypeOf(commonIL.NodeResources{})))),
+		},
+		APIV1Prefix + "/pods/stats": map[string]interface{}{
+			"post": withBody(operation("Get the resource usage of the given Pods", jsonResponse("Pod stats. Pods whose sidecar does not report stats are left out", podStats)),
+				jsonRequestBody(pods)),
+		},
+		APIV1Prefix + "/pods/{namespace}/{name}/logs": map[string]interface{}{
+			"parameters": []interface{}{
+				pathParameter("namespace"),
+				pathParameter("name"),
+				queryParameter("uid", "string", "UID of the Pod"),
+				queryParameter("container", "string", "Name of the container"),
+				queryParameter("tailLines", "integer", "Number of lines from the end of the logs to return"),
+				queryParameter("limitBytes", "integer", "Number of bytes from the end of the logs to return"),
+				queryParameter("timestamps", "boolean", "Add timestamps to every line"),
+				queryParameter("follow", "boolean", "Stream the logs as they are produced"),
+				queryParameter("previous", "boolean", "Return the logs of the previous container instance"),
+				queryParameter("sinceSeconds", "integer", "Return logs newer than a relative duration"),
+				queryParameter("sinceTime", "string", "Return logs newer than a RFC3339 timestamp"),
+			},
+			"get": operation("Get the logs of a container", textResponse("Container logs, streamed when following")),
+		},
+		APIV1Prefix + "/pods/{namespace}/{name}/exec": map[string]interface{}{
+			"parameters": append([]interface{}{pathParameter("namespace"), pathParameter("name")}, streamParameters()...),
+			"get":        operation("Run a command in a container", g.streamResponse("Websocket stream of the command, relayed to the sidecar")),
+		},
+		APIV1Prefix + "/pods/{namespace}/{name}/attach": map[string]interface{}{
+			"parameters": append([]interface{}{pathParameter("namespace"), pathParameter("name")}, streamParameters()...),
+			"get":        operation("Attach to the main process of a container", g.streamResponse("Websocket stream of the process, relayed to the sidecar")),
+		},
+		APIV1Prefix + "/pods/{namespace}/{name}/portforward": map[string]interface{}{
+			"parameters": []interface{}{pathParameter("namespace"), pathParameter("name"),
+				queryParameter("uid", "string", "UID of the Pod"), queryParameter("port", "integer", "Port of the Pod to forward the connection to")},
+			"get": operation("Forward a connection to a port of the Pod", portForwardResponse("Websocket stream of the connection, relayed to the sidecar")),
+		},
+		APIV1Prefix + "/cache/{uid}": map[string]interface{}{
+			"parameters": []interface{}{pathParameter("uid")},
+			"delete":     operation("Drop a Pod from the InterLink cache", textResponse("Cache updated")),
+		},
+		APIV1Prefix + "/operations/create": map[string]interface{}{
+			"parameters": []interface{}{idempotencyKey},
+			"post":       withBody(operation("Create a Pod on its sidecar in the background. Answered with 409 while the previous creation of the Pod failed with an unknown outcome, until the sidecar reports the Pod, or that it is not found, on /status, or the Pod is deleted", acceptedResponse("Operation creating the Pod", asyncOperation)), jsonRequestBody(podCreateRequests)),
+		},
+		APIV1Prefix + "/operations/create/batch": map[string]interface{}{
+			"post": withBody(operation("Create many Pods in the background, with an operation per Pod run as a single batch. Every Pod carries its own idempotency key, "+
+				"and a Pod whose previous creation failed with an unknown outcome gets that operation back",
+				acceptedResponse("Operations creating the Pods, in the request order", g.schemaFor(reflect.TypeOf([]commonIL.Operation{})))),
+				jsonRequestBody(g.schemaFor(reflect.TypeOf([]commonIL.CreateOperationRequest{})))),
+		},
+		APIV1Prefix + "/operations/delete": map[string]interface{}{
+			"parameters": []interface{}{idempotencyKey},
+			"post":       withBody(operation("Delete a Pod from its sidecar in the background", acceptedResponse("Operation deleting the Pod", asyncOperation)), jsonRequestBody(pod)),
+		},
+		APIV1Prefix + "/operations": map[string]interface{}{
+			"parameters": []interface{}{queryParameter("uid", "string", "UID of the Pod to list the operations of")},
+			"get":        operation("List the operations, oldest first", jsonResponse("Operations", g.schemaFor(reflect.TypeOf([]commonIL.Operation{})))),
+		},
+		APIV1Prefix + "/operations/{id}": map[string]interface{}{
+			"parameters": []interface{}{pathParameter("id")},
+			"get":        operation("Get an operation", jsonResponse("Operation", asyncOperation)),
+		},
+		APIV1Prefix + "/drain": map[string]interface{}{
+			"get":    operation("Get the drain status", jsonResponse("Drain status", drainStatus)),
+			"post":   operation("Refuse new Pods, while still serving status, logs and delete calls. Served on the AdminAddress listener only", jsonResponse("Drain status", drainStatus)),
+			"delete": operation("Accept new Pods again. Served on the AdminAddress listener only", jsonResponse("Drain status", drainStatus)),
+		},
+		APIV1Prefix + "/sidecars": map[string]interface{}{
+			"get": operation("List the sidecars with their health", jsonResponse("Sidecars health", g.schemaFor(reflect.TypeOf([]SidecarHealth{})))),
+		},
+		APIV1Prefix + "/ping": map[string]interface{}{
+			"get": operation("Ping InterLink", textResponse("0 if KUBECONFIG is set on InterLink, 1 otherwise")),
+		},
+		"/create": map[string]interface{}{
+			"post": deprecated(withBody(operation("Create a Pod on its sidecar", textResponse("Response of the sidecar")), jsonRequestBody(podCreateRequests))),
+		},
+		"/delete": map[string]interface{}{
+			"delete": deprecated(withBody(operation("Delete a Pod from its sidecar", jsonResponse("Deleted Pods", podStatuses)), jsonRequestBody(pod))),
+		},
+		"/status": map[string]interface{}{
+			"get": deprecated(withBody(operation("Get the status of the given Pods", jsonResponse("Pod statuses", podStatuses)), jsonRequestBody(pods))),
+		},
+		"/getLogs": map[string]interface{}{
+			"get": deprecated(withBody(operation("Get the logs of a container", textResponse("Container logs")), jsonRequestBody(logStruct))),
+		},
+		"/updateCache": map[string]interface{}{
+			"post": deprecated(withBody(operation("Drop a Pod from the InterLink cache", textResponse("Cache updated")),
+				map[string]interface{}{"required": true, "content": map[string]interface{}{"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}})),
+		},
+		"/pinglink": map[string]interface{}{
+			"post": deprecated(operation("Ping InterLink", textResponse("0 if KUBECONFIG is set on InterLink, 1 otherwise"))),
+		},
+	}
+
+	// every failure is answered with a commonIL.Error body
+	errorResponse := map[string]interface{}{"description": "Failure", "content": jsonContent(g.schemaFor(reflect.TypeOf(commonIL.Error{})))}
+	for _, pathItem := range paths {
+		for _, op := range pathItem.(map[string]interface{}) {
+			if op, ok := op.(map[string]interface{}); ok {
+				op["responses"].(map[string]interface{})["default"] = errorResponse
+			}
+		}
+	}
+
+	return document("interLink API", "openapi spec for VK <-> interLink apis communication", paths, g)
+}
+
+// SidecarOpenAPI returns the OpenAPI document of the API a sidecar plugin has to implement, generated from the Go types InterLink sends.
+func SidecarOpenAPI() map[string]interface{} {
+	g := &schemaGenerator{schemas: map[string]interface{}{}}
+
+	withBody := func(op map[string]interface{}, body map[string]interface{}) map[string]interface{} {
+		op["requestBody"] = body
+		return op
+	}
+
+	paths := map[string]interface{}{
+		"/create": map[string]interface{}{
+			"post": withBody(operation("Create Pods", jsonResponse("Outcome of every Pod. Plain text answers apply to the whole batch", g.schemaFor(reflect.TypeOf([]commonIL.PodCreateResult{})))),
+				jsonRequestBody(g.schemaFor(reflect.TypeOf([]commonIL.RetrievedPodData{})))),
+		},
+		"/delete": map[string]interface{}{
+			"delete": withBody(operation("Delete a Pod. Sidecars answering 405 are sent a POST instead, as in the legacy protocol", textResponse("Pod deleted")), jsonRequestBody(g.schemaFor(reflect.TypeOf(v1.Pod{})))),
+		},
+		"/status": map[string]interface{}{
+			"get": withBody(operation("Get the status of the given Pods. The Pods missing from the remote resource are reported with notFound set", jsonResponse("Pod statuses", g.schemaFor(reflect.TypeOf([]commonIL.PodStatus{})))),
+				jsonRequestBody(g.schemaFor(reflect.TypeOf([]v1.Pod{})))),
+		},
+		"/resources": map[string]interface{}{
+			"get": operation("Get the resources of the remote site. Optional, answered with 501 by plugins not supporting it",
+				jsonResponse("Resources of the remote site", g.schemaFor(reflect.TypeOf(commonIL.NodeResources{})))),
+		},
+		"/stats": map[string]interface{}{
+			"get": withBody(operation("Get the resource usage of the given Pods. Optional, answered with 501 by plugins not supporting it",
+				jsonResponse("Pod stats. Pods whose usage cannot be measured are left out", g.schemaFor(reflect.TypeOf([]commonIL.PodStats{})))),
+				jsonRequestBody(g.schemaFor(reflect.TypeOf([]v1.Pod{})))),
+		},
+		"/getLogs": map[string]interface{}{
+			"get": withBody(operation("Get the logs of a container", textResponse("Container logs, streamed when following")),
+				jsonRequestBody(g.schemaFor(reflect.TypeOf(commonIL.LogStruct{})))),
+		},
+		"/exec": map[string]interface{}{
+			"parameters": append([]interface{}{queryParameter("namespace", "string", "Namespace of the Pod"), queryParameter("name", "string", "Name of the Pod")}, streamParameters()...),
+			"get":        operation("Run a command in a container. Optional, answered with 501 by plugins not supporting it", g.streamResponse("Websocket stream of the command")),
+		},
+		"/attach": map[string]interface{}{
+			"parameters": append([]interface{}{queryParameter("namespace", "string", "Namespace of the Pod"), queryParameter("name", "string", "Name of the Pod")}, streamParameters()...),
+			"get":        operation("Attach to the main process of a container. Optional, answered with 501 by plugins not supporting it", g.streamResponse("Websocket stream of the process")),
+		},
+		"/portforward": map[string]interface{}{
+			"parameters": []interface{}{queryParameter("namespace", "string", "Namespace of the Pod"), queryParameter("name", "string", "Name of the Pod"),
+				queryParameter("uid", "string", "UID of the Pod"), queryParameter("port", "integer", "Port of the Pod to forward the connection to")},
+			"get": operation("Forward a connection to a port of the Pod. Optional, answered with 501 by plugins not supporting it", portForwardResponse("Websocket stream of the connection")),
+		},
+	}
+
+	return document("interLink sidecar", "openapi spec for interLink apis <-> provider sidecar communication", paths, g)
+}
@@ -0,0 +1,534 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/log"
+	v1 "k8s.io/api/core/v1"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+const (
+	// IdempotencyKeyHeader is the header carrying the idempotency key of an asynchronous create or delete
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// operationRetention is how long finished operations are kept, so that late retries still find them
+	operationRetention = 24 * time.Hour
+)
+
+// errOutcomeUnknown is returned when a creation is submitted while the outcome of the previous creation of the Pod is unknown
+var errOutcomeUnknown = errors.New("the outcome of the previous creation of the Pod is unknown")
+
+// operationStore holds the asynchronous operations. The latest operation of every type is indexed per Pod UID,
+// so that repeated submits for the same Pod are answered with the operation already there.
+type operationStore struct {
+	mu     sync.Mutex
+	byID   map[string]*commonIL.Operation
+	latest map[string]string
+	done   map[string]chan struct{}
+}
+
+// Operations holds the asynchronous Pod creations and deletions submitted to InterLink
+var Operations = operationStore{
+	byID:   make(map[string]*commonIL.Operation),
+	latest: make(map[string]string),
+	done:   make(map[string]chan struct{}),
+}
+
+func operationKey(opType commonIL.OperationType, uid string) string {
+	return string(opType) + "/" + uid
+}
+
+// load adds an operation read from the on-disk store. Operations still running when InterLink stopped are marked as failed,
+// as their outcome is unknown: the Pod may or may not exist on the sidecar.
+func (s *operationStore) load(op *commonIL.Operation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !op.Finished() {
+		op.State = commonIL.OperationFailed
+		op.Error = &commonIL.Error{Code: http.StatusInternalServerError, Message: "operation interrupted by an InterLink restart, the outcome on the sidecar is unknown"}
+		op.OutcomeUnknown = true
+		op.UpdatedAt = time.Now()
+	}
+	s.byID[op.ID] = op
+	key := operationKey(op.Type, op.PodUID)
+	if latest, ok := s.byID[s.latest[key]]; !ok || latest.CreatedAt.Before(op.CreatedAt) {
+		s.latest[key] = op.ID
+	}
+}
+
+// submit returns the operation to run for the Pod, and true if it is a new one.
+// The latest operation for the same Pod is returned instead, unless it failed and the idempotency key differs,
+// so that a repeated submit never creates a second remote job. For the same reason, a creation with another idempotency key
+// is refused with errOutcomeUnknown while the latest one failed with an unknown outcome, until checked.
+func (s *operationStore) submit(opType commonIL.OperationType, pod *v1.Pod, idempotencyKey string) (commonIL.Operation, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	key := operationKey(opType, string(pod.UID))
+	if existing, ok := s.byID[s.latest[key]]; ok {
+		if existing.State != commonIL.OperationFailed || idempotencyKey != "" && existing.IdempotencyKey == idempotencyKey {
+			return *existing, false, nil
+		}
+		if opType == commonIL.OperationCreate && existing.OutcomeUnknown {
+			return *existing, false, errOutcomeUnknown
+		}
+	}
+
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return commonIL.Operation{}, false, err
+	}
+	now := time.Now()
+	op := &commonIL.Operation{
+		ID:             hex.EncodeToString(id),
+		Type:           opType,
+		PodName:        pod.Name,
+		PodUID:         string(pod.UID),
+		PodNamespace:   pod.Namespace,
+		IdempotencyKey: idempotencyKey,
+		State:          commonIL.OperationPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	s.byID[op.ID] = op
+	s.latest[key] = op.ID
+	s.done[op.ID] = make(chan struct{})
+	s.persist(op)
+	return *op, true, nil
+}
+
+// update applies change to the operation and persists it
+func (s *operationStore) update(id string, change func(op *commonIL.Operation)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.byID[id]
+	if !ok {
+		return
+	}
+	change(op)
+	op.UpdatedAt = time.Now()
+	s.persist(op)
+
+	if done, ok := s.done[id]; ok && op.Finished() {
+		close(done)
+		delete(s.done, id)
+	}
+}
+
+// checked clears the unknown outcome of the latest creation of the Pod, once the sidecar reported its status, or that it is not found,
+// or once it was deleted: the caller knows whether the Pod is there, and may create it again
+func (s *operationStore) checked(uid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.byID[s.latest[operationKey(commonIL.OperationCreate, uid)]]
+	if !ok || !op.OutcomeUnknown {
+		return
+	}
+	op.OutcomeUnknown = false
+	op.UpdatedAt = time.Now()
+	s.persist(op)
+}
+
+// wait returns once the latest operation of the given type for the Pod is finished, or with the ctx error
+func (s *operationStore) wait(ctx context.Context, opType commonIL.OperationType, uid string) error {
+	s.mu.Lock()
+	done, ok := s.done[s.latest[operationKey(opType, uid)]]
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *operationStore) get(id string) (commonIL.Operation, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	op, ok := s.byID[id]
+	if !ok {
+		return commonIL.Operation{}, false
+	}
+	return *op, true
+}
+
+// list returns the operations, oldest first. A non empty uid returns the operations of that Pod only.
+func (s *operationStore) list(uid string) []commonIL.Operation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	ops := []commonIL.Operation{}
+	for _, op := range s.byID {
+		if uid == "" || op.PodUID == uid {
+			ops = append(ops, *op)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].CreatedAt.Before(ops[j].CreatedAt)
+	})
+	return ops
+}
+
+// prune drops the operations finished for longer than operationRetention. s.mu must be held by the caller.
+func (s *operationStore) prune() {
+	for id, op := range s.byID {
+		if !op.Finished() || time.Since(op.UpdatedAt) < operationRetention {
+			continue
+		}
+		delete(s.byID, id)
+		if key := operationKey(op.Type, op.PodUID); s.latest[key] == id {
+			delete(s.latest, key)
+		}
+		err := forgetOperation(id)
+		if err != nil {
+			log.L.Warning("Unable to remove operation " + id + " from the on-disk store: " + err.Error())
+		}
+	}
+}
+
+// persist writes the operation to the on-disk store. s.mu must be held by the caller.
+func (s *operationStore) persist(op *commonIL.Operation) {
+	err := persistOperation(op)
+	if err != nil {
+		log.L.Warning("Unable to persist operation " + op.ID + ": " + err.Error())
+	}
+}
+
+// operationWriter collects the response of the handler run by an operation
+type operationWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (w *operationWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *operationWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *operationWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+// error returns the error answered by the handler, either as JSON or as plain text
+func (w *operationWriter) error() *commonIL.Error {
+	apiErr := &commonIL.Error{}
+	if json.Unmarshal(w.body.Bytes(), apiErr) != nil || apiErr.Message == "" {
+		apiErr = &commonIL.Error{Code: w.statusCode, Message: w.body.String()}
+	}
+	return apiErr
+}
+
+// runOperation serves body with the synchronous handler in the background and records its outcome in the operation.
+// Deletions wait for the creation of the same Pod to be over, so that the Pod is not created on the sidecar once deleted.
+func (h *InterLinkHandler) runOperation(ctx context.Context, op commonIL.Operation, handler http.HandlerFunc, body []byte) {
+	// shutdown waits for the running operations, as it does for the synchronous calls
+	h.drain.begin(false)
+	go func() {
+		defer h.drain.end()
+
+		if op.Type == commonIL.OperationDelete {
+			err := Operations.wait(ctx, commonIL.OperationCreate, op.PodUID)
+			if err != nil {
+				log.G(h.Ctx).Warning("Operation " + op.ID + " did not wait for the creation of Pod " + op.PodNamespace + "/" + op.PodName + ": " + err.Error())
+			}
+		}
+
+		Operations.update(op.ID, func(op *commonIL.Operation) {
+			op.State = commonIL.OperationRunning
+		})
+		log.G(h.Ctx).Info("InterLink: running " + string(op.Type) + " operation " + op.ID + " for Pod " + op.PodNamespace + "/" + op.PodName)
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", bytes.NewReader(body))
+		writer := &operationWriter{header: make(http.Header)}
+		if err == nil {
+			handler(writer, req)
+		}
+
+		Operations.update(op.ID, func(op *commonIL.Operation) {
+			switch {
+			case err != nil:
+				op.State = commonIL.OperationFailed
+				op.Error = &commonIL.Error{Code: http.StatusInternalServerError, Message: err.Error()}
+			case writer.statusCode == http.StatusOK || writer.statusCode == 0:
+				op.State = commonIL.OperationSucceeded
+				op.Result = operationResult(writer.body.Bytes())
+			default:
+				failOperation(op, writer.error())
+			}
+		})
+	}()
+}
+
+// runCreateBatch creates the Pods of the operations in the background with a single BatchCreateHandler call, as runOperation does
+// for a single operation, and records the outcome of every Pod in its own operation
+func (h *InterLinkHandler) runCreateBatch(ctx context.Context, ops []commonIL.Operation, pods []commonIL.PodCreateRequests) {
+	h.drain.begin(false)
+	go func() {
+		defer h.drain.end()
+
+		for _, op := range ops {
+			Operations.update(op.ID, func(op *commonIL.Operation) {
+				op.State = commonIL.OperationRunning
+			})
+		}
+		log.G(h.Ctx).Info("InterLink: running " + strconv.Itoa(len(ops)) + " create operations as a single batch")
+
+		writer := &operationWriter{header: make(http.Header)}
+		body, err := json.Marshal(pods)
+		var req *http.Request
+		if err == nil {
+			req, err = http.NewRequestWithContext(ctx, http.MethodPost, "/", bytes.NewReader(body))
+		}
+		if err == nil {
+			h.BatchCreateHandler(writer, req)
+		}
+
+		var results []commonIL.PodCreateResult
+		var resultErr error
+		if err == nil && (writer.statusCode == http.StatusOK || writer.statusCode == 0) {
+			resultErr = json.Unmarshal(writer.body.Bytes(), &results)
+			if resultErr == nil && len(results) != len(ops) {
+				resultErr = fmt.Errorf("expected %d results, got %d", len(ops), len(results))
+			}
+		}
+
+		for i, op := range ops {
+			Operations.update(op.ID, func(op *commonIL.Operation) {
+				switch {
+				case err != nil:
+					op.State = commonIL.OperationFailed
+					op.Error = &commonIL.Error{Code: http.StatusInternalServerError, Message: err.Error()}
+				case writer.statusCode != http.StatusOK && writer.statusCode != 0:
+					failOperation(op, writer.error())
+				case resultErr != nil:
+					// the Pods went to the sidecars, but their outcome was lost
+					failOperation(op, &commonIL.Error{Code: http.StatusBadGateway, Message: "invalid batch Create result: " + resultErr.Error()})
+				case results[i].Error != nil:
+					failOperation(op, results[i].Error)
+				default:
+					op.State = commonIL.OperationSucceeded
+					op.Result, _ = json.Marshal(results[i])
+				}
+			})
+		}
+	}()
+}
+
+// failOperation marks the operation as failed with apiErr. When the sidecar timed out or could not be reached, the outcome is unknown,
+// as the sidecar may have applied the operation anyway. A call refused by the open circuit breaker of the sidecar was never sent,
+// so its outcome is known.
+func failOperation(op *commonIL.Operation, apiErr *commonIL.Error) {
+	op.State = commonIL.OperationFailed
+	op.Error = apiErr
+	op.OutcomeUnknown = sidecarMayHaveApplied(apiErr.Code)
+}
+
+// operationResult returns the response of the sidecar as JSON, quoting it if it is plain text
+func operationResult(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return body
+	}
+	quoted, _ := json.Marshal(string(body))
+	return quoted
+}
+
+// submitOperation answers with 202 and the operation for the Pod, starting it unless an operation for the same Pod is already there
+func (h *InterLinkHandler) submitOperation(w http.ResponseWriter, r *http.Request, opType commonIL.OperationType, pod *v1.Pod, handler http.HandlerFunc, body []byte) {
+	if pod.UID == "" {
+		h.writeError(w, http.StatusBadRequest, "the Pod UID is needed to submit an operation")
+		return
+	}
+
+	op, created, err := Operations.submit(opType, pod, r.Header.Get(IdempotencyKeyHeader))
+	if errors.Is(err, errOutcomeUnknown) {
+		h.writeError(w, http.StatusConflict, err.Error()+", operation "+op.ID+": check the status of the Pod or delete it before creating it again")
+		return
+	} else if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if created {
+		h.runOperation(sidecarContext(r), op, handler, body)
+	} else {
+		log.G(h.Ctx).Info("InterLink: " + string(opType) + " of Pod " + pod.Namespace + "/" + pod.Name + " already submitted as operation " + op.ID)
+	}
+
+	returnValue, err := json.Marshal(op)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", APIV1Prefix+"/operations/"+op.ID)
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(returnValue)
+}
+
+// CreateOperationHandler serves POST /api/v1/operations/create: the Pod is created in the background by a new operation,
+// unless an operation creating the same Pod is already there. It answers with 409 Conflict while the latest creation of the Pod
+// failed with an unknown outcome and the Pod was neither checked nor deleted since.
+func (h *InterLinkHandler) CreateOperationHandler(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "unable to read the Create request: "+err.Error())
+		return
+	}
+
+	var pod commonIL.PodCreateRequests
+	err = json.Unmarshal(bodyBytes, &pod)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid Create request: "+err.Error())
+		return
+	}
+
+	h.submitOperation(w, r, commonIL.OperationCreate, &pod.Pod, h.CreateHandler, bodyBytes)
+}
+
+// BatchCreateOperationHandler serves POST /api/v1/operations/create/batch: every Pod is created by its own operation, with its own
+// idempotency key, as with CreateOperationHandler, but the new operations are run together as a single BatchCreateHandler call.
+// It answers with 202 and the operations, in the request order. A Pod whose latest creation failed with an unknown outcome,
+// and wasn't checked since, gets that operation back instead of a new one.
+func (h *InterLinkHandler) BatchCreateOperationHandler(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "unable to read the batch Create request: "+err.Error())
+		return
+	}
+
+	var reqs []commonIL.CreateOperationRequest
+	err = json.Unmarshal(bodyBytes, &reqs)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid batch Create request: "+err.Error())
+		return
+	}
+	for _, req := range reqs {
+		if req.Pod.UID == "" {
+			h.writeError(w, http.StatusBadRequest, "the Pod UID is needed to submit an operation, missing for Pod "+req.Pod.Namespace+"/"+req.Pod.Name)
+			return
+		}
+	}
+
+	ops := make([]commonIL.Operation, 0, len(reqs))
+	var created []commonIL.Operation
+	var pods []commonIL.PodCreateRequests
+	for _, req := range reqs {
+		var op commonIL.Operation
+		var isNew bool
+		op, isNew, err = Operations.submit(commonIL.OperationCreate, &req.Pod, req.IdempotencyKey)
+		if errors.Is(err, errOutcomeUnknown) {
+			log.G(h.Ctx).Warning("InterLink: " + err.Error() + ", returning operation " + op.ID + " for Pod " + req.Pod.Namespace + "/" + req.Pod.Name)
+			err = nil
+		} else if err != nil {
+			break
+		}
+		if isNew {
+			created = append(created, op)
+			pods = append(pods, req.PodCreateRequests)
+		} else {
+			log.G(h.Ctx).Info("InterLink: create of Pod " + req.Pod.Namespace + "/" + req.Pod.Name + " already submitted as operation " + op.ID)
+		}
+		ops = append(ops, op)
+	}
+	// the operations already submitted are run anyway, so that none is left pending
+	if len(created) > 0 {
+		h.runCreateBatch(sidecarContext(r), created, pods)
+	}
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	returnValue, err := json.Marshal(ops)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(returnValue)
+}
+
+// DeleteOperationHandler serves POST /api/v1/operations/delete: the Pod is deleted in the background by a new operation,
+// unless an operation deleting the same Pod is already there
+func (h *InterLinkHandler) DeleteOperationHandler(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "unable to read the Delete request: "+err.Error())
+		return
+	}
+
+	var pod v1.Pod
+	err = json.Unmarshal(bodyBytes, &pod)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid Delete request: "+err.Error())
+		return
+	}
+
+	h.submitOperation(w, r, commonIL.OperationDelete, &pod, h.DeleteHandler, bodyBytes)
+}
+
+// ListOperationsHandler serves GET /api/v1/operations, optionally filtered by Pod with the uid query parameter
+func (h *InterLinkHandler) ListOperationsHandler(w http.ResponseWriter, r *http.Request) {
+	returnValue, err := json.Marshal(Operations.list(r.URL.Query().Get("uid")))
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(returnValue)
+}
+
+// GetOperationHandler serves GET /api/v1/operations/{id}
+func (h *InterLinkHandler) GetOperationHandler(w http.ResponseWriter, r *http.Request) {
+	op, ok := Operations.get(r.PathValue("id"))
+	if !ok {
+		h.writeError(w, http.StatusNotFound, "operation "+r.PathValue("id")+" not found")
+		return
+	}
+
+	returnValue, err := json.Marshal(op)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(returnValue)
+}
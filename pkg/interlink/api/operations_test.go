@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// newTestHandler resets the operations and the status cache, and returns an InterLinkHandler whose default sidecar is served by sidecar
+func newTestHandler(t *testing.T, sidecar http.Handler) *InterLinkHandler {
+	t.Helper()
+	Operations = operationStore{
+		byID:   make(map[string]*commonIL.Operation),
+		latest: make(map[string]string),
+		done:   make(map[string]chan struct{}),
+	}
+	PodStatuses.Statuses = make(map[string]commonIL.PodStatus)
+
+	server := httptest.NewServer(sidecar)
+	t.Cleanup(server.Close)
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := commonIL.InterLinkConfig{Sidecarurl: "http://" + host, Sidecarport: port}
+	router, err := NewSidecarRouter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &InterLinkHandler{Config: config, Ctx: context.Background(), Sidecars: router}
+}
+
+func testPod(name string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + name)},
+		Status:     v1.PodStatus{Phase: v1.PodPending},
+	}
+}
+
+// failUnknown submits the creation of the Pod and fails it with an unknown outcome, as a sidecar timeout would
+func failUnknown(t *testing.T, pod *v1.Pod) {
+	t.Helper()
+	op, _, err := Operations.submit(commonIL.OperationCreate, pod, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Operations.update(op.ID, func(op *commonIL.Operation) {
+		failOperation(op, &commonIL.Error{Code: http.StatusGatewayTimeout, Message: "timeout"})
+	})
+}
+
+func TestStatusChecksReportedPods(t *testing.T) {
+	reported := testPod("reported")
+	h := newTestHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the sidecar reports the first Pod as not found and leaves the second one out
+		json.NewEncoder(w).Encode([]commonIL.PodStatus{{PodName: reported.Name, PodUID: string(reported.UID), PodNamespace: reported.Namespace, NotFound: true}})
+	}))
+	left := testPod("left")
+	failUnknown(t, reported)
+	failUnknown(t, left)
+
+	body, _ := json.Marshal([]*v1.Pod{reported, left})
+	w := httptest.NewRecorder()
+	h.StatusHandler(w, httptest.NewRequest(http.MethodPost, "/status", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	_, created, err := Operations.submit(commonIL.OperationCreate, reported, "second")
+	if err != nil || !created {
+		t.Errorf("expected a new creation of the Pod reported as not found, got %v", err)
+	}
+	_, _, err = Operations.submit(commonIL.OperationCreate, left, "second")
+	if !errors.Is(err, errOutcomeUnknown) {
+		t.Errorf("expected the outcome of the Pod left out of the answer to stay unknown, got %v", err)
+	}
+}
+
+// testSidecar serves /create, counting the calls, and /status, reporting every Pod as not found
+func testSidecar(creates *atomic.Int32) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/create", func(w http.ResponseWriter, r *http.Request) {
+		creates.Add(1)
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		var pods []*v1.Pod
+		json.NewDecoder(r.Body).Decode(&pods)
+		statuses := []commonIL.PodStatus{}
+		for _, pod := range pods {
+			statuses = append(statuses, commonIL.PodStatus{PodName: pod.Name, PodUID: string(pod.UID), PodNamespace: pod.Namespace, NotFound: true})
+		}
+		json.NewEncoder(w).Encode(statuses)
+	})
+	return mux
+}
+
+// submitCreate submits the creation of the Pod with CreateOperationHandler and returns the operation once it is over
+func submitCreate(t *testing.T, h *InterLinkHandler, pod *v1.Pod, idempotencyKey string) commonIL.Operation {
+	t.Helper()
+	body, _ := json.Marshal(commonIL.PodCreateRequests{Pod: *pod})
+	req := httptest.NewRequest(http.MethodPost, APIV1Prefix+"/operations/create", bytes.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, idempotencyKey)
+	w := httptest.NewRecorder()
+	h.CreateOperationHandler(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var op commonIL.Operation
+	err := json.Unmarshal(w.Body.Bytes(), &op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = Operations.wait(ctx, commonIL.OperationCreate, string(pod.UID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	op, _ = Operations.get(op.ID)
+	return op
+}
+
+func TestCreateRefusedByOpenCircuitIsNotUnknown(t *testing.T) {
+	var creates atomic.Int32
+	h := newTestHandler(t, testSidecar(&creates))
+	pod := testPod("circuit")
+
+	breaker := h.Sidecars.ForPod(string(pod.UID)).breaker
+	breaker.threshold = 1
+	breaker.failures = 1
+	breaker.openUntil = time.Now().Add(time.Hour)
+
+	op := submitCreate(t, h, pod, "first")
+	if op.State != commonIL.OperationFailed || op.Error == nil || op.Error.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected the creation to fail with 503, got %+v", op)
+	}
+	if op.OutcomeUnknown {
+		t.Error("expected the outcome of a creation refused by the open circuit breaker to be known")
+	}
+	if creates.Load() != 0 {
+		t.Errorf("expected no call to the sidecar, got %d", creates.Load())
+	}
+
+	// once the sidecar is back, the creation is sent again without any check
+	breaker.failures = 0
+	op = submitCreate(t, h, pod, "second")
+	if op.State != commonIL.OperationSucceeded {
+		t.Errorf("expected the second creation to succeed, got %+v", op)
+	}
+	if creates.Load() != 1 {
+		t.Errorf("expected a single call to the sidecar, got %d", creates.Load())
+	}
+}
+
+func TestCreateAfterCheckedAbsent(t *testing.T) {
+	var creates atomic.Int32
+	h := newTestHandler(t, testSidecar(&creates))
+	pod := testPod("absent")
+	failUnknown(t, pod)
+
+	body, _ := json.Marshal(commonIL.PodCreateRequests{Pod: *pod})
+	req := httptest.NewRequest(http.MethodPost, APIV1Prefix+"/operations/create", bytes.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, "second")
+	w := httptest.NewRecorder()
+	h.CreateOperationHandler(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 before the Pod is checked, got %d: %s", w.Code, w.Body.String())
+	}
+
+	body, _ = json.Marshal([]*v1.Pod{pod})
+	w = httptest.NewRecorder()
+	h.StatusHandler(w, httptest.NewRequest(http.MethodPost, APIV1Prefix+"/pods/status", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// the sidecar reported the Pod as not found: the creation is sent again
+	op := submitCreate(t, h, pod, "second")
+	if op.State != commonIL.OperationSucceeded || op.IdempotencyKey != "second" {
+		t.Errorf("expected a new successful creation, got %+v", op)
+	}
+	if creates.Load() != 1 {
+		t.Errorf("expected a single call to the sidecar, got %d", creates.Load())
+	}
+}
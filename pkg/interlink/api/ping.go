@@ -1,15 +1,38 @@
 package api
 
 import (
+	"encoding/json"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/containerd/containerd/log"
 )
 
-// Ping is just a very basic Ping function
+// SidecarHealth tells whether InterLink considers a sidecar able to serve calls
+type SidecarHealth struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+}
+
+// Ping is just a very basic Ping function. It answers 503 when the circuit breakers of every sidecar are open.
 func (h *InterLinkHandler) Ping(w http.ResponseWriter, r *http.Request) {
 	log.G(h.Ctx).Info("InterLink: received Ping call")
+
+	var unhealthy []string
+	sidecars := h.Sidecars.Sidecars()
+	for _, sidecar := range sidecars {
+		if !sidecar.Healthy() {
+			unhealthy = append(unhealthy, sidecar.Name)
+		}
+	}
+	if len(sidecars) > 0 && len(unhealthy) == len(sidecars) {
+		h.writeError(w, http.StatusServiceUnavailable, "every sidecar is unhealthy: "+strings.Join(unhealthy, ", "))
+		return
+	} else if len(unhealthy) > 0 {
+		log.G(h.Ctx).Warning("InterLink: unhealthy sidecars " + strings.Join(unhealthy, ", "))
+	}
+
 	w.WriteHeader(http.StatusOK)
 
 	// 0 = KUBECONFIG already set
@@ -20,3 +43,20 @@ func (h *InterLinkHandler) Ping(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("1"))
 	}
 }
+
+// SidecarsHealthHandler lists the configured sidecars with their health
+func (h *InterLinkHandler) SidecarsHealthHandler(w http.ResponseWriter, r *http.Request) {
+	var health []SidecarHealth
+	for _, sidecar := range h.Sidecars.Sidecars() {
+		health = append(health, SidecarHealth{Name: sidecar.Name, Healthy: sidecar.Healthy()})
+	}
+
+	returnValue, err := json.Marshal(health)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(returnValue)
+}
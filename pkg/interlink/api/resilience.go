@@ -0,0 +1,329 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/log"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// Defaults of the sidecar client, used when SidecarClient leaves them unset
+const (
+	DefaultSidecarTimeout   = 2 * time.Minute
+	DefaultMaxRetries       = 2
+	DefaultRetryBackoff     = 500 * time.Millisecond
+	DefaultMaxRetryBackoff  = 10 * time.Second
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// defaultRouteTimeouts are the sidecar timeouts per route. Creations may pull images or submit jobs, so they get more time.
+var defaultRouteTimeouts = map[string]time.Duration{
+	"/create":    5 * time.Minute,
+	"/delete":    2 * time.Minute,
+	"/status":    30 * time.Second,
+	"/getLogs":   30 * time.Second,
+	"/stats":     30 * time.Second,
+	"/resources": 30 * time.Second,
+}
+
+// idempotentRoutes are the sidecar routes retried on failure
+var idempotentRoutes = map[string]bool{
+	"/status":    true,
+	"/getLogs":   true,
+	"/delete":    true,
+	"/stats":     true,
+	"/resources": true,
+}
+
+// streamingRoutes are the sidecar routes whose timeout only bounds the time to the response headers, as their body may be endless
+var streamingRoutes = map[string]bool{
+	"/getLogs": true,
+}
+
+// ErrCircuitOpen is returned without calling the sidecar while its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// SidecarTimeoutError is returned when the sidecar does not answer within the route timeout
+type SidecarTimeoutError struct {
+	Sidecar string
+	Route   string
+	Timeout time.Duration
+}
+
+func (e *SidecarTimeoutError) Error() string {
+	return fmt.Sprintf("sidecar %s did not answer %s within %s", e.Sidecar, e.Route, e.Timeout)
+}
+
+// sidecarPolicy holds the parsed SidecarClient configuration
+type sidecarPolicy struct {
+	timeouts         map[string]time.Duration
+	defaultTimeout   time.Duration
+	maxRetries       int
+	retryBackoff     time.Duration
+	maxRetryBackoff  time.Duration
+	breakerThreshold int
+	breakerCooldown  time.Duration
+}
+
+// newSidecarPolicy parses the SidecarClient configuration, filling the unset values with the defaults
+func newSidecarPolicy(config commonIL.SidecarClientConfig) (*sidecarPolicy, error) {
+	policy := &sidecarPolicy{
+		timeouts:         make(map[string]time.Duration),
+		maxRetries:       config.MaxRetries,
+		breakerThreshold: config.BreakerThreshold,
+	}
+	for route, timeout := range defaultRouteTimeouts {
+		policy.timeouts[route] = timeout
+	}
+
+	for route, value := range config.Timeouts {
+		timeout, err := parseDuration("Timeouts."+route, value, 0)
+		if err != nil {
+			return nil, err
+		}
+		policy.timeouts[route] = timeout
+	}
+
+	var err error
+	policy.defaultTimeout, err = parseDuration("DefaultTimeout", config.DefaultTimeout, DefaultSidecarTimeout)
+	if err != nil {
+		return nil, err
+	}
+	policy.retryBackoff, err = parseDuration("RetryBackoff", config.RetryBackoff, DefaultRetryBackoff)
+	if err != nil {
+		return nil, err
+	}
+	policy.maxRetryBackoff, err = parseDuration("MaxRetryBackoff", config.MaxRetryBackoff, DefaultMaxRetryBackoff)
+	if err != nil {
+		return nil, err
+	}
+	policy.breakerCooldown, err = parseDuration("BreakerCooldown", config.BreakerCooldown, DefaultBreakerCooldown)
+	if err != nil {
+		return nil, err
+	}
+
+	if policy.maxRetries == 0 {
+		policy.maxRetries = DefaultMaxRetries
+	}
+	if policy.breakerThreshold == 0 {
+		policy.breakerThreshold = DefaultBreakerThreshold
+	}
+	return policy, nil
+}
+
+func parseDuration(name string, value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid SidecarClient.%s %q: %w", name, value, err)
+	}
+	return duration, nil
+}
+
+func (p *sidecarPolicy) timeout(route string) time.Duration {
+	if timeout, ok := p.timeouts[route]; ok {
+		return timeout
+	}
+	return p.defaultTimeout
+}
+
+// backoff returns the wait before the given retry: exponential, capped to maxRetryBackoff, with full jitter
+func (p *sidecarPolicy) backoff(retry int) time.Duration {
+	backoff := p.retryBackoff << retry
+	if backoff <= 0 || backoff > p.maxRetryBackoff {
+		backoff = p.maxRetryBackoff
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
+
+// circuitBreaker opens after threshold consecutive failures and lets a single probe call through once cooldown is over
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow returns false while the breaker is open. Once the cooldown is over, only one probe call is allowed at a time.
+func (b *circuitBreaker) allow() bool {
+	if b.threshold < 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record updates the breaker with the outcome of a call
+func (b *circuitBreaker) record(success bool) {
+	if b.threshold < 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// healthy returns false while the breaker is open. Once the cooldown is over the breaker is half-open and the sidecar
+// is reported healthy again, so that callers go on sending calls and the next one probes the sidecar.
+func (b *circuitBreaker) healthy() bool {
+	if b.threshold < 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.failures < b.threshold || !time.Now().Before(b.openUntil)
+}
+
+// resilientTransport bounds every sidecar call with the route timeout, retries the idempotent ones
+// and fails fast while the sidecar circuit breaker is open
+type resilientTransport struct {
+	sidecar string
+	policy  *sidecarPolicy
+	breaker *circuitBreaker
+	next    http.RoundTripper
+}
+
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	route := req.URL.Path
+	retries := 0
+	if idempotentRoutes[route] && t.policy.maxRetries > 0 {
+		retries = t.policy.maxRetries
+	}
+
+	// the body of requests built from a bytes.Reader can be sent again
+	canReplay := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.attempt(req, route)
+		// 501 tells that the sidecar does not implement the route: asking again won't change it
+		retryable := err != nil && !errors.Is(err, ErrCircuitOpen) ||
+			err == nil && resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode != http.StatusNotImplemented
+		if !retryable || attempt >= retries || !canReplay || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		if err != nil {
+			log.L.Warning("Retrying " + route + " call to sidecar " + t.sidecar + ": " + err.Error())
+		} else {
+			log.L.Warning(fmt.Sprintf("Retrying %s call to sidecar %s: status code %d", route, t.sidecar, resp.StatusCode))
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(t.policy.backoff(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// attempt performs a single call, bounded by the route timeout
+func (t *resilientTransport) attempt(req *http.Request, route string) (*http.Response, error) {
+	if !t.breaker.allow() {
+		return nil, fmt.Errorf("sidecar %s: %w", t.sidecar, ErrCircuitOpen)
+	}
+
+	timeout := t.policy.timeout(route)
+	ctx, cancel := context.WithCancel(req.Context())
+	timedOut := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() {
+		close(timedOut)
+		cancel()
+	})
+	stop := func() {
+		timer.Stop()
+		cancel()
+	}
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		stop()
+		select {
+		case <-timedOut:
+			err = &SidecarTimeoutError{Sidecar: t.sidecar, Route: route, Timeout: timeout}
+		default:
+		}
+		// calls cancelled by the caller say nothing about the sidecar health
+		if req.Context().Err() == nil {
+			t.breaker.record(false)
+		}
+		return nil, err
+	}
+
+	t.breaker.record(!sidecarUnavailable(resp.StatusCode))
+	if streamingRoutes[route] {
+		// the sidecar answered in time: the stream may now last as long as needed
+		timer.Stop()
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: stop}
+	return resp, nil
+}
+
+// sidecarUnavailable returns true for the status codes telling that the sidecar, rather than the call, has a problem
+func sidecarUnavailable(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout
+}
+
+// sidecarMayHaveApplied returns true for the failures after which the sidecar may have applied the call anyway: 502 and 504.
+// 503 is only answered while the circuit breaker of the sidecar is open, without sending the call.
+func sidecarMayHaveApplied(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusGatewayTimeout
+}
+
+// cancelOnClose releases the call context once the response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
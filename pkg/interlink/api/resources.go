@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/containerd/containerd/log"
+	v1 "k8s.io/api/core/v1"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// ResourcesHandler serves GET /api/v1/resources, returning the sum of the resources reported by every sidecar.
+// Sidecars not implementing /resources are left out; if none implements it, the call is answered with 501 Not Implemented.
+// Sidecars failing or unreachable are left out too, so that a single sidecar does not freeze the capacity of the node:
+// only when no sidecar reports its resources and some failed, the call is answered with 502 Bad Gateway.
+func (h *InterLinkHandler) ResourcesHandler(w http.ResponseWriter, r *http.Request) {
+	log.G(h.Ctx).Info("InterLink: received GetResources call")
+
+	total := commonIL.NodeResources{Capacity: v1.ResourceList{}, Allocatable: v1.ResourceList{}}
+	reported := false
+	var failed []string
+	for _, sidecar := range h.Sidecars.Sidecars() {
+		resources, implemented, err := h.sidecarResources(r, sidecar)
+		if err != nil {
+			log.G(h.Ctx).Warning("InterLink: no resources from sidecar " + sidecar.Name + ": " + err.Error())
+			failed = append(failed, sidecar.Name)
+			continue
+		} else if !implemented {
+			log.G(h.Ctx).Debug("InterLink: sidecar " + sidecar.Name + " does not report its resources")
+			continue
+		}
+		addResources(total.Capacity, resources.Capacity)
+		addResources(total.Allocatable, resources.AllocatableOrCapacity())
+		reported = true
+	}
+
+	if !reported && len(failed) > 0 {
+		h.writeError(w, http.StatusBadGateway, "unable to get the resources of sidecars "+strings.Join(failed, ", "))
+		return
+	} else if !reported {
+		h.writeError(w, http.StatusNotImplemented, "no sidecar reports its resources")
+		return
+	}
+
+	returnValue, err := json.Marshal(total)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(returnValue)
+}
+
+// sidecarResources asks the sidecar for its resources. implemented is false if the sidecar answers 404 or 501.
+func (h *InterLinkHandler) sidecarResources(r *http.Request, sidecar *Sidecar) (resources commonIL.NodeResources, implemented bool, err error) {
+	req, err := http.NewRequestWithContext(sidecarContext(r), http.MethodGet, sidecar.Endpoint+"/resources", nil)
+	if err != nil {
+		return resources, false, err
+	}
+
+	log.G(h.Ctx).Info("InterLink: forwarding GetResources call to sidecar " + sidecar.Name)
+	resp, err := sidecar.Client.Do(req)
+	if err != nil {
+		return resources, false, err
+	}
+	bodyBytes, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return resources, false, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusNotImplemented {
+		return resources, false, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return resources, false, fmt.Errorf("status code %d: %s", resp.StatusCode, strings.TrimSpace(string(bodyBytes)))
+	}
+
+	err = json.Unmarshal(bodyBytes, &resources)
+	if err != nil {
+		return resources, false, fmt.Errorf("invalid response: %w", err)
+	}
+	return resources, true, nil
+}
+
+// addResources adds every quantity of resources to total
+func addResources(total v1.ResourceList, resources v1.ResourceList) {
+	for name, quantity := range resources {
+		sum := total[name]
+		sum.Add(quantity)
+		total[name] = sum
+	}
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/containerd/containerd/log"
+	"github.com/gorilla/websocket"
+	v1 "k8s.io/api/core/v1"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+const (
+	// SidecarAnnotation lets a Pod explicitly choose the sidecar it runs on, bypassing the routing rules
+	SidecarAnnotation = "interlink.eu/sidecar"
+	// DefaultSidecarName is the name of the sidecar built from SidecarURL and SidecarPort when no Sidecars are listed
+	DefaultSidecarName = "default"
+)
+
+// Sidecar is a sidecar InterLink forwards calls to
+type Sidecar struct {
+	Name     string
+	Endpoint string
+	// Client performs the calls to the sidecar. It dials the socket when the sidecar listens on a unix:// URL
+	// and uses the sidecar TLS options when the sidecar is served over https://. Every call is traced, bounded by the route timeout,
+	// retried when idempotent, guarded by the circuit breaker and recorded in the sidecar metrics
+	Client *http.Client
+
+	breaker *circuitBreaker
+	// dialer opens the exec and attach websocket streams, through the same socket and TLS options as Client
+	dialer *websocket.Dialer
+}
+
+// Healthy returns false while the circuit breaker of the sidecar is open, i.e. after too many consecutive failed calls and until the cooldown is over
+func (s *Sidecar) Healthy() bool {
+	return s.breaker.healthy()
+}
+
+// SidecarRouter picks the sidecar of every Pod and remembers the choice per Pod UID,
+// so that status, logs and delete calls reach the same backend the Pod has been created on.
+type SidecarRouter struct {
+	sidecars       map[string]*Sidecar
+	routes         []commonIL.SidecarRoute
+	defaultSidecar string
+
+	mu       sync.Mutex
+	assigned map[string]string
+}
+
+// NewSidecarRouter builds the sidecars and the routing rules from the InterLink configuration
+func NewSidecarRouter(config commonIL.InterLinkConfig) (*SidecarRouter, error) {
+	sidecarConfigs := config.Sidecars
+	if len(sidecarConfigs) == 0 {
+		sidecarConfigs = []commonIL.SidecarConfig{{
+			Name: DefaultSidecarName,
+			URL:  config.Sidecarurl,
+			Port: config.Sidecarport,
+			TLS:  config.SidecarTLS,
+		}}
+	}
+
+	policy, err := newSidecarPolicy(config.SidecarClient)
+	if err != nil {
+		return nil, err
+	}
+
+	router := &SidecarRouter{
+		sidecars:       make(map[string]*Sidecar),
+		routes:         config.SidecarRoutes,
+		defaultSidecar: config.DefaultSidecar,
+		assigned:       make(map[string]string),
+	}
+
+	for _, sidecarConfig := range sidecarConfigs {
+		if sidecarConfig.Name == "" {
+			return nil, fmt.Errorf("every sidecar needs a Name")
+		}
+		if _, ok := router.sidecars[sidecarConfig.Name]; ok {
+			return nil, fmt.Errorf("sidecar %s is listed more than once", sidecarConfig.Name)
+		}
+		sidecar, err := newSidecar(sidecarConfig, policy)
+		if err != nil {
+			return nil, err
+		}
+		router.sidecars[sidecar.Name] = sidecar
+	}
+
+	if router.defaultSidecar == "" {
+		router.defaultSidecar = sidecarConfigs[0].Name
+	}
+	if _, ok := router.sidecars[router.defaultSidecar]; !ok {
+		return nil, fmt.Errorf("default sidecar %s is not listed in Sidecars", router.defaultSidecar)
+	}
+	for _, route := range router.routes {
+		if _, ok := router.sidecars[route.Sidecar]; !ok {
+			return nil, fmt.Errorf("routing rule points to unknown sidecar %s", route.Sidecar)
+		}
+	}
+
+	return router, nil
+}
+
+// newSidecar builds the endpoint and the HTTP client of a sidecar
+func newSidecar(config commonIL.SidecarConfig, policy *sidecarPolicy) (*Sidecar, error) {
+	sidecar := &Sidecar{
+		Name:    config.Name,
+		breaker: &circuitBreaker{threshold: policy.breakerThreshold, cooldown: policy.breakerCooldown},
+	}
+
+	unixSocket := commonIL.IsUnixSocket(config.URL)
+	var transport *http.Transport
+	if unixSocket {
+		sidecar.Endpoint = commonIL.UnixSocketHost
+		if config.TLS.Enabled() {
+			sidecar.Endpoint = commonIL.UnixSocketTLSHost
+		}
+		transport = commonIL.UnixSocketTransport(commonIL.UnixSocketPath(config.URL))
+	} else if strings.HasPrefix(config.URL, "http://") && config.TLS.Enabled() {
+		return nil, fmt.Errorf("sidecar %s has TLS options but a plain http:// URL: use https:// instead", config.Name)
+	} else if strings.HasPrefix(config.URL, "http://") || strings.HasPrefix(config.URL, "https://") {
+		sidecar.Endpoint = config.URL + ":" + config.Port
+	} else {
+		return nil, fmt.Errorf("URL of sidecar %s should either start per unix://, http:// or https://", config.Name)
+	}
+
+	if config.TLS.Enabled() {
+		tlsConfig, err := config.TLS.ClientTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		if unixSocket {
+			tlsConfig = commonIL.UnixSocketTLSConfig(tlsConfig)
+		}
+		if transport == nil {
+			transport = http.DefaultTransport.(*http.Transport).Clone()
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	sidecar.dialer = commonIL.WebsocketDialer(transport)
+
+	var next http.RoundTripper = http.DefaultTransport
+	if transport != nil {
+		next = transport
+	}
+	sidecar.Client = &http.Client{Transport: &tracingTransport{
+		sidecar: sidecar.Name,
+		next: &resilientTransport{
+			sidecar: sidecar.Name,
+			policy:  policy,
+			breaker: sidecar.breaker,
+			next:    &sidecarTransport{sidecar: sidecar.Name, next: next},
+		},
+	}}
+	return sidecar, nil
+}
+
+// Route returns the sidecar the Pod has already been assigned to or, for new Pods, the one chosen by
+// the SidecarAnnotation or by the first matching routing rule. The choice is remembered per Pod UID.
+func (r *SidecarRouter) Route(pod *v1.Pod) (*Sidecar, error) {
+	uid := string(pod.UID)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if name, ok := r.assigned[uid]; ok {
+		return r.sidecars[name], nil
+	}
+
+	name := r.defaultSidecar
+	if annotated, ok := pod.Annotations[SidecarAnnotation]; ok {
+		if _, exists := r.sidecars[annotated]; !exists {
+			return nil, fmt.Errorf("pod %s/%s asks for unknown sidecar %s", pod.Namespace, pod.Name, annotated)
+		}
+		name = annotated
+	} else {
+		for _, route := range r.routes {
+			if routeMatches(route, pod) {
+				name = route.Sidecar
+				break
+			}
+		}
+	}
+
+	r.assign(uid, name)
+	return r.sidecars[name], nil
+}
+
+// ForPod returns the sidecar the Pod identified by uid has been assigned to, or the default one for unknown Pods
+func (r *SidecarRouter) ForPod(uid string) *Sidecar {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if name, ok := r.assigned[uid]; ok {
+		if sidecar, exists := r.sidecars[name]; exists {
+			return sidecar
+		}
+		log.L.Warning("Pod " + uid + " was assigned to sidecar " + name + ", which is not configured anymore")
+	}
+	return r.sidecars[r.defaultSidecar]
+}
+
+// Sidecars returns every configured sidecar, sorted by name
+func (r *SidecarRouter) Sidecars() []*Sidecar {
+	sidecars := make([]*Sidecar, 0, len(r.sidecars))
+	for _, sidecar := range r.sidecars {
+		sidecars = append(sidecars, sidecar)
+	}
+	sort.Slice(sidecars, func(i, j int) bool { return sidecars[i].Name < sidecars[j].Name })
+	return sidecars
+}
+
+// Forget drops the sidecar assignment of the Pod identified by uid
+func (r *SidecarRouter) Forget(uid string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.assigned, uid)
+	err := forgetAssignment(uid)
+	if err != nil {
+		log.L.Error(err)
+	}
+}
+
+// LoadAssignments restores the sidecar assignments persisted in the on-disk store, if open
+func (r *SidecarRouter) LoadAssignments() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return loadAssignments(r.assigned)
+}
+
+// assign remembers the sidecar of the Pod identified by uid. r.mu must be held by the caller.
+func (r *SidecarRouter) assign(uid string, name string) {
+	r.assigned[uid] = name
+	err := persistAssignment(uid, name)
+	if err != nil {
+		log.L.Error(err)
+	}
+}
+
+// routeMatches returns true if the Pod satisfies every condition set in the route
+func routeMatches(route commonIL.SidecarRoute, pod *v1.Pod) bool {
+	for key, value := range route.Annotations {
+		if pod.Annotations[key] != value {
+			return false
+		}
+	}
+
+	if route.RuntimeClassName != "" && (pod.Spec.RuntimeClassName == nil || *pod.Spec.RuntimeClassName != route.RuntimeClassName) {
+		return false
+	}
+
+	for key, value := range route.NodeSelector {
+		if pod.Spec.NodeSelector[key] != value {
+			return false
+		}
+	}
+
+	if len(route.Resources) > 0 && !requestsAnyResource(pod, route.Resources) {
+		return false
+	}
+
+	return true
+}
+
+// requestsAnyResource returns true if a container or init container of the Pod requests or limits one of the resources
+func requestsAnyResource(pod *v1.Pod, resources []string) bool {
+	containers := append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, container := range containers {
+		for _, resourceName := range resources {
+			name := v1.ResourceName(resourceName)
+			if quantity, ok := container.Resources.Requests[name]; ok && !quantity.IsZero() {
+				return true
+			}
+			if quantity, ok := container.Resources.Limits[name]; ok && !quantity.IsZero() {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/containerd/containerd/log"
+	v1 "k8s.io/api/core/v1"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// StatsHandler serves POST /api/v1/pods/stats, returning the resource usage of the given Pods as reported by their sidecars.
+// Stats are best effort: the Pods whose sidecar does not report stats, fails or is unreachable are left out of the response,
+// so that a single sidecar does not hide the usage of every other Pod.
+func (h *InterLinkHandler) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	var pods []*v1.Pod
+	log.G(h.Ctx).Info("InterLink: received GetStats call")
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(bodyBytes, &pods)
+	}
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid GetStats request: "+err.Error())
+		return
+	}
+
+	podsPerSidecar := make(map[*Sidecar][]*v1.Pod)
+	for _, pod := range pods {
+		// lookup only: reading a Pod never assigns it to a sidecar
+		sidecar := h.Sidecars.ForPod(string(pod.UID))
+		podsPerSidecar[sidecar] = append(podsPerSidecar[sidecar], pod)
+	}
+
+	returnStats := []commonIL.PodStats{}
+	for sidecar, sidecarPods := range podsPerSidecar {
+		sidecarStats, err := h.sidecarStats(r, sidecar, sidecarPods)
+		if err != nil {
+			log.G(h.Ctx).Warning("InterLink: no stats from sidecar " + sidecar.Name + " for " + strconv.Itoa(len(sidecarPods)) + " Pods: " + err.Error())
+			continue
+		}
+		returnStats = append(returnStats, sidecarStats...)
+	}
+
+	returnValue, err := json.Marshal(returnStats)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(returnValue)
+}
+
+// sidecarStats asks the sidecar for the stats of its Pods. Sidecars not implementing /stats report no stats at all.
+func (h *InterLinkHandler) sidecarStats(r *http.Request, sidecar *Sidecar, pods []*v1.Pod) ([]commonIL.PodStats, error) {
+	bodyBytes, err := json.Marshal(pods)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(sidecarContext(r), http.MethodGet, sidecar.Endpoint+"/stats", bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	log.G(h.Ctx).Info("InterLink: forwarding GetStats call to sidecar " + sidecar.Name)
+	resp, err := sidecar.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusNotImplemented {
+		log.G(h.Ctx).Debug("InterLink: sidecar " + sidecar.Name + " does not report stats")
+		return nil, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, &commonIL.Error{Code: resp.StatusCode, Message: "status code " + strconv.Itoa(resp.StatusCode), Sidecar: sidecar.Name, SidecarMessage: string(bodyBytes)}
+	}
+
+	var stats []commonIL.PodStats
+	err = json.Unmarshal(bodyBytes, &stats)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
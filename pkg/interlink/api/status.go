@@ -8,30 +8,35 @@ import (
 	"strconv"
 
 	"github.com/containerd/containerd/log"
+	"go.opentelemetry.io/otel/attribute"
 	v1 "k8s.io/api/core/v1"
 
 	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
 )
 
 func (h *InterLinkHandler) StatusHandler(w http.ResponseWriter, r *http.Request) {
-	statusCode := http.StatusOK
 	var pods []*v1.Pod
 	log.G(h.Ctx).Info("InterLink: received GetStatus call")
 
 	bodyBytes, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.G(h.Ctx).Fatal(err)
+		h.writeError(w, http.StatusBadRequest, "unable to read the GetStatus request: "+err.Error())
+		return
 	}
 
-	err = json.Unmarshal(bodyBytes, &pods)
-	if err != nil {
-		log.G(h.Ctx).Error(err)
+	// an empty request asks for every cached status
+	if len(bytes.TrimSpace(bodyBytes)) > 0 {
+		err = json.Unmarshal(bodyBytes, &pods)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid GetStatus request: "+err.Error())
+			return
+		}
 	}
 
 	var podsToBeChecked []*v1.Pod
-	var returnedStatuses []commonIL.PodStatus //returned from the query to the sidecar
-	var returnPods []commonIL.PodStatus       //returned to the vk
+	var returnPods []commonIL.PodStatus //returned to the vk
 
+	_, cacheSpan := startSpan(r.Context(), "InterLink.cacheLookup", attribute.Int("interlink.pods", len(pods)))
 	PodStatuses.mu.Lock()
 	for _, pod := range pods {
 		cached := checkIfCached(string(pod.UID))
@@ -40,57 +45,73 @@ func (h *InterLinkHandler) StatusHandler(w http.ResponseWriter, r *http.Request)
 		}
 	}
 	PodStatuses.mu.Unlock()
+	cacheSpan.SetAttributes(attribute.Int("interlink.pods_to_check", len(podsToBeChecked)))
+	cacheSpan.End()
+
+	podsPerSidecar := make(map[*Sidecar][]*v1.Pod)
+	for _, pod := range podsToBeChecked {
+		// lookup only: reading a Pod never assigns it to a sidecar
+		sidecar := h.Sidecars.ForPod(string(pod.UID))
+		podsPerSidecar[sidecar] = append(podsPerSidecar[sidecar], pod)
+	}
 
-	if len(podsToBeChecked) > 0 {
+	for sidecar, sidecarPods := range podsPerSidecar {
+		var returnedStatuses []commonIL.PodStatus //returned from the query to the sidecar
 
-		bodyBytes, err = json.Marshal(podsToBeChecked)
+		bodyBytes, err = json.Marshal(sidecarPods)
 		if err != nil {
-			log.G(h.Ctx).Fatal(err)
+			h.writeError(w, http.StatusInternalServerError, "unable to marshal the GetStatus request for the sidecar: "+err.Error())
+			return
 		}
 
 		reader := bytes.NewReader(bodyBytes)
-		req, err := http.NewRequest(http.MethodGet, h.SidecarEndpoint+"/status", reader)
+		req, err := http.NewRequestWithContext(sidecarContext(r), http.MethodGet, sidecar.Endpoint+"/status", reader)
 		if err != nil {
-			log.G(h.Ctx).Fatal(err)
+			h.writeError(w, http.StatusInternalServerError, err.Error())
+			return
 		}
 
-		log.G(h.Ctx).Info("InterLink: forwarding GetStatus call to sidecar")
+		log.G(h.Ctx).Info("InterLink: forwarding GetStatus call to sidecar " + sidecar.Name)
 		req.Header.Set("Content-Type", "application/json")
 		log.G(h.Ctx).Debug(req)
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := sidecar.Client.Do(req)
 		if err != nil {
-			statusCode = http.StatusInternalServerError
-			w.WriteHeader(statusCode)
-			log.G(h.Ctx).Error(err)
+			h.writeSidecarUnreachable(w, sidecar, err)
 			return
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			log.L.Error("Unexpected error occured. Status code: " + strconv.Itoa(resp.StatusCode) + ". Check Sidecar's logs for further informations")
-			statusCode = http.StatusInternalServerError
-		}
-
 		bodyBytes, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
 		if err != nil {
-			statusCode = http.StatusInternalServerError
-			w.WriteHeader(statusCode)
-			log.G(h.Ctx).Error(err)
+			h.writeSidecarError(w, http.StatusBadGateway, "unable to read the GetStatus response of sidecar "+sidecar.Name+": "+err.Error(), sidecar, nil)
+			return
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			h.writeSidecarError(w, http.StatusInternalServerError, "sidecar "+sidecar.Name+" failed to get the status of Pods with status code "+strconv.Itoa(resp.StatusCode), sidecar, bodyBytes)
 			return
 		}
 
 		log.G(h.Ctx).Debug(string(bodyBytes))
 		err = json.Unmarshal(bodyBytes, &returnedStatuses)
 		if err != nil {
-			statusCode = http.StatusInternalServerError
-			w.WriteHeader(statusCode)
-			log.G(h.Ctx).Error(err)
+			h.writeSidecarError(w, http.StatusBadGateway, "invalid GetStatus response from sidecar "+sidecar.Name+": "+err.Error(), sidecar, bodyBytes)
 			return
 		}
 
+		_, updateSpan := startSpan(r.Context(), "InterLink.cacheUpdate", attribute.Int("interlink.pods", len(returnedStatuses)))
 		updateStatuses(returnedStatuses)
+		updateSpan.End()
+
+		// the Pods reported by the sidecar, either with their status or as not found, are known to be there or not,
+		// including those whose creation had an unknown outcome. The Pods left out of the answer are still unknown.
+		for _, status := range returnedStatuses {
+			Operations.checked(status.PodUID)
+		}
 
 	}
 
+	_, readSpan := startSpan(r.Context(), "InterLink.cacheRead")
 	if len(pods) > 0 {
 		for _, pod := range pods {
 			PodStatuses.mu.Lock()
@@ -103,20 +124,22 @@ func (h *InterLinkHandler) StatusHandler(w http.ResponseWriter, r *http.Request)
 			PodStatuses.mu.Unlock()
 		}
 	} else {
+		PodStatuses.mu.Lock()
 		for _, pod := range PodStatuses.Statuses {
 			returnPods = append(returnPods, pod)
 		}
+		PodStatuses.mu.Unlock()
 	}
+	readSpan.SetAttributes(attribute.Int("interlink.pods", len(returnPods)))
+	readSpan.End()
 
 	returnValue, err := json.Marshal(returnPods)
 	if err != nil {
-		statusCode = http.StatusInternalServerError
-		w.WriteHeader(statusCode)
-		log.G(h.Ctx).Error(err)
+		h.writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	log.G(h.Ctx).Debug("InterLink: status " + string(returnValue))
 
-	w.WriteHeader(statusCode)
+	w.WriteHeader(http.StatusOK)
 	w.Write(returnValue)
 }
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/containerd/containerd/log"
+	bolt "go.etcd.io/bbolt"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+const (
+	// StatusDBFile is the name of the file, inside DataRootFolder, where Pod statuses, sidecar assignments and operations are persisted
+	StatusDBFile    = "statuses.db"
+	statusBucket    = "statuses"
+	sidecarBucket   = "sidecars"
+	operationBucket = "operations"
+)
+
+// OpenStatusDB opens (creating it if needed) the on-disk store for Pod statuses under dataRootFolder
+// and loads every persisted status in the PodStatuses map, and every persisted operation in Operations.
+// From now on, updateStatuses and deleteCachedStatus write through to the store.
+func OpenStatusDB(dataRootFolder string) error {
+	ops, err := openStatusDB(dataRootFolder)
+	if err != nil {
+		return err
+	}
+	// Operations are loaded once PodStatuses.mu is released, as the operation store locks it in turn to persist operations
+	for _, op := range ops {
+		Operations.load(op)
+	}
+	return nil
+}
+
+func openStatusDB(dataRootFolder string) ([]*commonIL.Operation, error) {
+	err := os.MkdirAll(dataRootFolder, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(filepath.Join(dataRootFolder, StatusDBFile), 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	PodStatuses.mu.Lock()
+	defer PodStatuses.mu.Unlock()
+
+	if PodStatuses.Statuses == nil {
+		PodStatuses.Statuses = make(map[string]commonIL.PodStatus)
+	}
+
+	var ops []*commonIL.Operation
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(sidecarBucket))
+		if err != nil {
+			return err
+		}
+		operations, err := tx.CreateBucketIfNotExists([]byte(operationBucket))
+		if err != nil {
+			return err
+		}
+		err = operations.ForEach(func(id, value []byte) error {
+			var op commonIL.Operation
+			err := json.Unmarshal(value, &op)
+			if err != nil {
+				log.L.Warning("Skipping corrupted operation " + string(id) + ": " + err.Error())
+				return nil
+			}
+			ops = append(ops, &op)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		bucket, err := tx.CreateBucketIfNotExists([]byte(statusBucket))
+		if err != nil {
+			return err
+		}
+		return bucket.ForEach(func(uid, value []byte) error {
+			var status commonIL.PodStatus
+			err := json.Unmarshal(value, &status)
+			if err != nil {
+				log.L.Warning("Skipping corrupted cached status for pod " + string(uid) + ": " + err.Error())
+				return nil
+			}
+			PodStatuses.Statuses[string(uid)] = status
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	PodStatuses.db = db
+	return ops, nil
+}
+
+// CloseStatusDB flushes and closes the on-disk store for Pod statuses, if any
+func CloseStatusDB() error {
+	PodStatuses.mu.Lock()
+	defer PodStatuses.mu.Unlock()
+
+	if PodStatuses.db == nil {
+		return nil
+	}
+	err := PodStatuses.db.Close()
+	PodStatuses.db = nil
+	return err
+}
+
+// persistStatuses writes the statuses to the on-disk store. PodStatuses.mu must be held by the caller.
+func persistStatuses(statuses []commonIL.PodStatus) error {
+	if PodStatuses.db == nil {
+		return nil
+	}
+	return PodStatuses.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(statusBucket))
+		for _, status := range statuses {
+			value, err := json.Marshal(status)
+			if err != nil {
+				return err
+			}
+			err = bucket.Put([]byte(status.PodUID), value)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// forgetStatus removes the uid key from the on-disk store. PodStatuses.mu must be held by the caller.
+func forgetStatus(uid string) error {
+	if PodStatuses.db == nil {
+		return nil
+	}
+	return PodStatuses.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(statusBucket)).Delete([]byte(uid))
+	})
+}
+
+// persistAssignment writes the sidecar assigned to the Pod identified by uid to the on-disk store
+func persistAssignment(uid string, sidecar string) error {
+	PodStatuses.mu.Lock()
+	defer PodStatuses.mu.Unlock()
+
+	if PodStatuses.db == nil {
+		return nil
+	}
+	return PodStatuses.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(sidecarBucket)).Put([]byte(uid), []byte(sidecar))
+	})
+}
+
+// forgetAssignment removes the sidecar assigned to the Pod identified by uid from the on-disk store
+func forgetAssignment(uid string) error {
+	PodStatuses.mu.Lock()
+	defer PodStatuses.mu.Unlock()
+
+	if PodStatuses.db == nil {
+		return nil
+	}
+	return PodStatuses.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(sidecarBucket)).Delete([]byte(uid))
+	})
+}
+
+// loadAssignments reads every sidecar assignment persisted in the on-disk store into assigned
+func loadAssignments(assigned map[string]string) error {
+	PodStatuses.mu.Lock()
+	defer PodStatuses.mu.Unlock()
+
+	if PodStatuses.db == nil {
+		return nil
+	}
+	return PodStatuses.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(sidecarBucket)).ForEach(func(uid, sidecar []byte) error {
+			assigned[string(uid)] = string(sidecar)
+			return nil
+		})
+	})
+}
+
+// persistOperation writes the operation to the on-disk store
+func persistOperation(op *commonIL.Operation) error {
+	PodStatuses.mu.Lock()
+	defer PodStatuses.mu.Unlock()
+
+	if PodStatuses.db == nil {
+		return nil
+	}
+	value, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	return PodStatuses.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(operationBucket)).Put([]byte(op.ID), value)
+	})
+}
+
+// forgetOperation removes the operation identified by id from the on-disk store
+func forgetOperation(id string) error {
+	PodStatuses.mu.Lock()
+	defer PodStatuses.mu.Unlock()
+
+	if PodStatuses.db == nil {
+		return nil
+	}
+	return PodStatuses.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(operationBucket)).Delete([]byte(id))
+	})
+}
@@ -12,14 +12,14 @@ func (h *InterLinkHandler) UpdateCacheHandler(w http.ResponseWriter, r *http.Req
 	log.G(h.Ctx).Info("InterLink: received UpdateCache call")
 
 	bodyBytes, err := io.ReadAll(r.Body)
-	statusCode := http.StatusOK
 	if err != nil {
-		statusCode = http.StatusInternalServerError
-		log.G(h.Ctx).Fatal(err)
+		h.writeError(w, http.StatusBadRequest, "unable to read the UpdateCache request: "+err.Error())
+		return
 	}
 
 	deleteCachedStatus(string(bodyBytes))
+	h.Sidecars.Forget(string(bodyBytes))
 
-	w.WriteHeader(statusCode)
+	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Updated cache"))
 }
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// APIV1Prefix is the root of the versioned InterLink API
+const APIV1Prefix = "/api/v1"
+
+// RegisterRoutes registers the versioned /api/v1 routes and the legacy flat routes, kept as aliases, on mux
+func (h *InterLinkHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.Handle("POST "+APIV1Prefix+"/pods", instrument("create", h.tracked(true, h.CreateHandler)))
+	mux.Handle("POST "+APIV1Prefix+"/pods/batch", instrument("batchCreate", h.tracked(true, h.BatchCreateHandler)))
+	mux.Handle("DELETE "+APIV1Prefix+"/pods/{namespace}/{name}", instrument("delete", h.tracked(false, h.DeletePodV1Handler)))
+	mux.Handle("GET "+APIV1Prefix+"/pods/status", instrument("status", h.ListStatusV1Handler))
+	mux.Handle("POST "+APIV1Prefix+"/pods/status", instrument("status", h.StatusHandler))
+	mux.Handle("POST "+APIV1Prefix+"/pods/stats", instrument("stats", h.StatsHandler))
+	mux.Handle("GET "+APIV1Prefix+"/pods/{namespace}/{name}/logs", instrument("logs", h.GetLogsV1Handler))
+	mux.Handle("GET "+APIV1Prefix+"/pods/{namespace}/{name}/exec", instrument("exec", h.ExecHandler))
+	mux.Handle("GET "+APIV1Prefix+"/pods/{namespace}/{name}/attach", instrument("attach", h.AttachHandler))
+	mux.Handle("GET "+APIV1Prefix+"/pods/{namespace}/{name}/portforward", instrument("portForward", h.PortForwardHandler))
+	mux.Handle("DELETE "+APIV1Prefix+"/cache/{uid}", instrument("updateCache", h.DeleteCacheV1Handler))
+	mux.Handle("POST "+APIV1Prefix+"/operations/create", instrument("createOperation", h.tracked(true, h.CreateOperationHandler)))
+	mux.Handle("POST "+APIV1Prefix+"/operations/create/batch", instrument("batchCreateOperation", h.tracked(true, h.BatchCreateOperationHandler)))
+	mux.Handle("POST "+APIV1Prefix+"/operations/delete", instrument("deleteOperation", h.tracked(false, h.DeleteOperationHandler)))
+	mux.Handle("GET "+APIV1Prefix+"/operations", instrument("operations", h.ListOperationsHandler))
+	mux.Handle("GET "+APIV1Prefix+"/operations/{id}", instrument("operations", h.GetOperationHandler))
+	mux.Handle("GET "+APIV1Prefix+"/ping", instrument("ping", h.Ping))
+	mux.Handle("GET "+APIV1Prefix+"/resources", instrument("resources", h.ResourcesHandler))
+	mux.Handle("GET "+APIV1Prefix+"/sidecars", instrument("sidecars", h.SidecarsHealthHandler))
+	mux.Handle("GET "+APIV1Prefix+"/openapi.json", instrument("openapi", h.OpenAPIHandler))
+	mux.Handle("GET "+APIV1Prefix+"/drain", instrument("drain", h.DrainHandler))
+
+	mux.Handle("/status", instrument("status", h.StatusHandler))
+	mux.Handle("/create", instrument("create", h.tracked(true, h.CreateHandler)))
+	mux.Handle("/delete", instrument("delete", h.tracked(false, h.DeleteHandler)))
+	mux.Handle("/pinglink", instrument("ping", h.Ping))
+	mux.Handle("/getLogs", instrument("logs", h.GetLogsHandler))
+	mux.Handle("/updateCache", instrument("updateCache", h.UpdateCacheHandler))
+}
+
+// RegisterAdminRoutes registers the routes changing the state of InterLink itself, i.e. starting and stopping the drain, on mux.
+// They are meant for the admin listener only: the credentials of the VKs must not be enough to stop InterLink from accepting Pods.
+func (h *InterLinkHandler) RegisterAdminRoutes(mux *http.ServeMux) {
+	mux.Handle("GET "+APIV1Prefix+"/drain", instrument("drain", h.DrainHandler))
+	mux.Handle("POST "+APIV1Prefix+"/drain", instrument("drain", h.DrainHandler))
+	mux.Handle("DELETE "+APIV1Prefix+"/drain", instrument("drain", h.DrainHandler))
+}
+
+// setBody replaces the request body, so that versioned routes can be served by the legacy handlers
+func setBody(r *http.Request, body []byte) {
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+}
+
+// DeletePodV1Handler serves DELETE /api/v1/pods/{namespace}/{name}. The body holds the Pod to be deleted, which must match the path.
+func (h *InterLinkHandler) DeletePodV1Handler(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var pod v1.Pod
+	err = json.Unmarshal(bodyBytes, &pod)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if pod.Namespace != r.PathValue("namespace") || pod.Name != r.PathValue("name") {
+		h.writeError(w, http.StatusBadRequest, "Pod in the body does not match "+r.PathValue("namespace")+"/"+r.PathValue("name"))
+		return
+	}
+
+	setBody(r, bodyBytes)
+	h.DeleteHandler(w, r)
+}
+
+// ListStatusV1Handler serves GET /api/v1/pods/status, returning every cached status
+func (h *InterLinkHandler) ListStatusV1Handler(w http.ResponseWriter, r *http.Request) {
+	setBody(r, []byte("[]"))
+	h.StatusHandler(w, r)
+}
+
+// GetLogsV1Handler serves GET /api/v1/pods/{namespace}/{name}/logs. Log options are passed as query parameters,
+// named after the kubectl ones: container, uid, tailLines, limitBytes, timestamps, follow, previous, sinceSeconds and sinceTime.
+func (h *InterLinkHandler) GetLogsV1Handler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	logsRequest := commonIL.LogStruct{
+		Namespace:     r.PathValue("namespace"),
+		PodName:       r.PathValue("name"),
+		PodUID:        query.Get("uid"),
+		ContainerName: query.Get("container"),
+	}
+
+	var err error
+	parseInt := func(name string, out *int) {
+		if value := query.Get(name); value != "" && err == nil {
+			*out, err = strconv.Atoi(value)
+		}
+	}
+	parseBool := func(name string, out *bool) {
+		if value := query.Get(name); value != "" && err == nil {
+			*out, err = strconv.ParseBool(value)
+		}
+	}
+	parseInt("tailLines", &logsRequest.Opts.Tail)
+	parseInt("limitBytes", &logsRequest.Opts.LimitBytes)
+	parseInt("sinceSeconds", &logsRequest.Opts.SinceSeconds)
+	parseBool("timestamps", &logsRequest.Opts.Timestamps)
+	parseBool("follow", &logsRequest.Opts.Follow)
+	parseBool("previous", &logsRequest.Opts.Previous)
+	if value := query.Get("sinceTime"); value != "" && err == nil {
+		logsRequest.Opts.SinceTime, err = time.Parse(time.RFC3339, value)
+	}
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid log options: "+err.Error())
+		return
+	}
+
+	bodyBytes, err := json.Marshal(logsRequest)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	setBody(r, bodyBytes)
+	h.GetLogsHandler(w, r)
+}
+
+// DeleteCacheV1Handler serves DELETE /api/v1/cache/{uid}, dropping the Pod from the InterLink cache
+func (h *InterLinkHandler) DeleteCacheV1Handler(w http.ResponseWriter, r *http.Request) {
+	setBody(r, []byte(r.PathValue("uid")))
+	h.UpdateCacheHandler(w, r)
+}
+
+// OpenAPIHandler serves the OpenAPI document of the InterLink API
+func (h *InterLinkHandler) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	returnValue, err := json.Marshal(InterLinkOpenAPI())
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(returnValue)
+}
@@ -0,0 +1,312 @@
+// Package auth validates the OIDC/JWT bearer tokens sent to the InterLink API,
+// replacing the need of an external oauth2-proxy in front of InterLink.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/log"
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+const (
+	// DefaultGroupClaim is the claim holding the user groups when GroupClaim is not set
+	DefaultGroupClaim = "groups"
+	// minRefreshInterval limits how often remote keys are fetched again when a token is signed by an unknown key
+	minRefreshInterval = time.Minute
+)
+
+// allowedAlgorithms are the signature algorithms accepted for bearer tokens. Symmetric algorithms and "none" are refused.
+var allowedAlgorithms = map[string]bool{
+	string(jose.RS256): true,
+	string(jose.RS384): true,
+	string(jose.RS512): true,
+	string(jose.PS256): true,
+	string(jose.PS384): true,
+	string(jose.PS512): true,
+	string(jose.ES256): true,
+	string(jose.ES384): true,
+	string(jose.ES512): true,
+	string(jose.EdDSA): true,
+}
+
+// UnauthorizedError is returned when a token is missing, malformed, not correctly signed or expired
+type UnauthorizedError struct {
+	Reason string
+}
+
+func (e *UnauthorizedError) Error() string {
+	return "unauthorized: " + e.Reason
+}
+
+// ForbiddenError is returned when a valid token does not satisfy the group or claim allow-lists
+type ForbiddenError struct {
+	Reason string
+}
+
+func (e *ForbiddenError) Error() string {
+	return "forbidden: " + e.Reason
+}
+
+// Validator validates bearer tokens against the keys of the configured issuer
+type Validator struct {
+	config     commonIL.OIDCConfig
+	httpClient *http.Client
+	ctx        context.Context
+
+	mu        sync.Mutex
+	keys      jose.JSONWebKeySet
+	jwksURL   string
+	lastFetch time.Time
+}
+
+// NewValidator returns a Validator for the given configuration.
+// Keys from JWKSFile must be readable at startup, while remote keys failing to load are fetched again on the next request.
+func NewValidator(ctx context.Context, config commonIL.OIDCConfig, httpClient *http.Client) (*Validator, error) {
+	if !config.Enabled() {
+		return nil, errors.New("one of Issuer, JWKSURL or JWKSFile is needed to validate tokens")
+	}
+	if config.GroupClaim == "" {
+		config.GroupClaim = DefaultGroupClaim
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	v := &Validator{
+		config:     config,
+		httpClient: httpClient,
+		ctx:        ctx,
+		jwksURL:    config.JWKSURL,
+	}
+
+	if config.JWKSFile != "" {
+		keys, err := loadJWKSFile(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+		return v, nil
+	}
+
+	v.mu.Lock()
+	err := v.refreshKeys()
+	v.mu.Unlock()
+	if err != nil {
+		log.G(ctx).Warning("Unable to fetch token signing keys, retrying on the next request: " + err.Error())
+	}
+	return v, nil
+}
+
+// Middleware rejects requests without a valid bearer token with 401, and requests not satisfying the allow-lists with 403
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := v.Validate(r.Header.Get("Authorization"))
+		if err != nil {
+			log.G(v.ctx).Warning("InterLink: refused " + r.URL.Path + " call. " + err.Error())
+			var forbidden *ForbiddenError
+			if errors.As(err, &forbidden) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Validate checks the bearer token in the authorization header value and returns its claims
+func (v *Validator) Validate(authorization string) (map[string]interface{}, error) {
+	rawToken, found := strings.CutPrefix(authorization, "Bearer ")
+	if !found || strings.TrimSpace(rawToken) == "" {
+		return nil, &UnauthorizedError{Reason: "missing bearer token"}
+	}
+
+	token, err := jwt.ParseSigned(strings.TrimSpace(rawToken))
+	if err != nil {
+		return nil, &UnauthorizedError{Reason: "malformed token: " + err.Error()}
+	}
+	if len(token.Headers) != 1 {
+		return nil, &UnauthorizedError{Reason: "tokens with multiple signatures are not supported"}
+	}
+	header := token.Headers[0]
+	if !allowedAlgorithms[header.Algorithm] {
+		return nil, &UnauthorizedError{Reason: "unsupported signing algorithm " + header.Algorithm}
+	}
+
+	var standard jwt.Claims
+	var claims map[string]interface{}
+	verified := false
+	for _, key := range v.signingKeys(header.KeyID) {
+		if key.Algorithm != "" && key.Algorithm != header.Algorithm {
+			continue
+		}
+		if token.Claims(key.Key, &standard, &claims) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, &UnauthorizedError{Reason: "token signature can't be verified"}
+	}
+
+	if standard.Expiry == nil {
+		return nil, &UnauthorizedError{Reason: "token has no expiration"}
+	}
+	err = standard.ValidateWithLeeway(jwt.Expected{Issuer: v.config.Issuer, Time: time.Now()}, jwt.DefaultLeeway)
+	if err != nil {
+		return nil, &UnauthorizedError{Reason: err.Error()}
+	}
+	if v.config.Audience != "" && !standard.Audience.Contains(v.config.Audience) {
+		return nil, &UnauthorizedError{Reason: "token is not issued for audience " + v.config.Audience}
+	}
+
+	err = v.authorize(claims)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// authorize checks the claims against the group and claim allow-lists
+func (v *Validator) authorize(claims map[string]interface{}) error {
+	if len(v.config.Groups) > 0 && !matchAny(claimValues(claims[v.config.GroupClaim]), v.config.Groups) {
+		return &ForbiddenError{Reason: "user is not member of any allowed group"}
+	}
+	for claim, allowed := range v.config.Claims {
+		if !matchAny(claimValues(claims[claim]), allowed) {
+			return &ForbiddenError{Reason: "claim " + claim + " has no allowed value"}
+		}
+	}
+	return nil
+}
+
+// signingKeys returns the keys matching kid (every key if kid is empty).
+// When no key matches and keys are remote, they are fetched again, at most once per minRefreshInterval.
+func (v *Validator) signingKeys(kid string) []jose.JSONWebKey {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := v.lookupKeys(kid)
+	if len(keys) == 0 && v.config.JWKSFile == "" && time.Since(v.lastFetch) > minRefreshInterval {
+		err := v.refreshKeys()
+		if err != nil {
+			log.G(v.ctx).Error(err)
+		}
+		keys = v.lookupKeys(kid)
+	}
+	return keys
+}
+
+// lookupKeys returns the keys matching kid (every key if kid is empty). v.mu must be held by the caller.
+func (v *Validator) lookupKeys(kid string) []jose.JSONWebKey {
+	if kid == "" {
+		return v.keys.Keys
+	}
+	return v.keys.Key(kid)
+}
+
+// refreshKeys fetches the keys from JWKSURL, discovering it from the issuer if not set. v.mu must be held by the caller.
+func (v *Validator) refreshKeys() error {
+	v.lastFetch = time.Now()
+
+	if v.jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		err := v.getJSON(strings.TrimSuffix(v.config.Issuer, "/")+"/.well-known/openid-configuration", &discovery)
+		if err != nil {
+			return err
+		}
+		if discovery.JWKSURI == "" {
+			return fmt.Errorf("issuer %s does not advertise a jwks_uri", v.config.Issuer)
+		}
+		v.jwksURL = discovery.JWKSURI
+	}
+
+	var keys jose.JSONWebKeySet
+	err := v.getJSON(v.jwksURL, &keys)
+	if err != nil {
+		return err
+	}
+	v.keys = keys
+	return nil
+}
+
+// getJSON performs a GET request and decodes the JSON response in out
+func (v *Validator) getJSON(url string, out interface{}) error {
+	ctx, cancel := context.WithTimeout(v.ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// loadJWKSFile reads a JSON Web Key Set from a local file
+func loadJWKSFile(path string) (jose.JSONWebKeySet, error) {
+	var keys jose.JSONWebKeySet
+	jwks, err := os.ReadFile(path)
+	if err != nil {
+		return keys, err
+	}
+	err = json.Unmarshal(jwks, &keys)
+	if err != nil {
+		return keys, fmt.Errorf("invalid JWKS file %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// claimValues converts a claim, either a single string or a list, to a list of strings
+func claimValues(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var values []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// matchAny returns true if at least one of values is in allowed
+func matchAny(values []string, allowed []string) bool {
+	for _, value := range values {
+		for _, a := range allowed {
+			if value == a {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+const (
+	testIssuer   = "https://issuer.example"
+	testAudience = "interlink"
+	testKeyID    = "test-key"
+)
+
+// testClaims are the claims of the tokens signed in the tests
+type testClaims struct {
+	jwt.Claims
+	Groups []string `json:"groups,omitempty"`
+	Site   string   `json:"site,omitempty"`
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func publicJWKS(key *rsa.PrivateKey) jose.JSONWebKeySet {
+	return jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: testKeyID, Algorithm: string(jose.RS256), Use: "sig"}}}
+}
+
+// sign returns the compact serialization of claims signed with key, with the given algorithm and the test key ID
+func sign(t *testing.T, alg jose.SignatureAlgorithm, key interface{}, claims testClaims) string {
+	t.Helper()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", testKeyID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// validClaims returns claims accepted by the test configuration, valid for an hour
+func validClaims() testClaims {
+	now := time.Now()
+	return testClaims{
+		Claims: jwt.Claims{
+			Issuer:   testIssuer,
+			Subject:  "vk",
+			Audience: jwt.Audience{testAudience},
+			IssuedAt: jwt.NewNumericDate(now),
+			Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+		Groups: []string{"interlink-users"},
+		Site:   "site-a",
+	}
+}
+
+// serveJWKS serves the JWKS of key and the discovery document of the test issuer pointing to it
+func serveJWKS(t *testing.T, key *rsa.PrivateKey) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": testIssuer, "jwks_uri": srv.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(publicJWKS(key))
+	})
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// writeJWKS writes the JWKS of key in a temporary file and returns its path
+func writeJWKS(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+	jwks, err := json.Marshal(publicJWKS(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	err = os.WriteFile(path, jwks, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestValidator(t *testing.T, config commonIL.OIDCConfig) *Validator {
+	t.Helper()
+	v, err := NewValidator(context.Background(), config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	key := generateKey(t)
+	otherKey := generateKey(t)
+	srv := serveJWKS(t, key)
+
+	validators := map[string]*Validator{
+		"JWKSURL":  newTestValidator(t, commonIL.OIDCConfig{Issuer: testIssuer, JWKSURL: srv.URL + "/jwks", Audience: testAudience}),
+		"JWKSFile": newTestValidator(t, commonIL.OIDCConfig{Issuer: testIssuer, JWKSFile: writeJWKS(t, key), Audience: testAudience}),
+	}
+
+	expired := validClaims()
+	expired.IssuedAt = jwt.NewNumericDate(time.Now().Add(-2 * time.Hour))
+	expired.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	noExpiry := validClaims()
+	noExpiry.Expiry = nil
+	wrongAudience := validClaims()
+	wrongAudience.Audience = jwt.Audience{"someone-else"}
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "https://evil.example"
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantErr       bool
+	}{
+		{name: "valid token", authorization: "Bearer " + sign(t, jose.RS256, key, validClaims())},
+		{name: "missing token", authorization: "", wantErr: true},
+		{name: "not a bearer token", authorization: "Basic dXNlcjpwYXNz", wantErr: true},
+		{name: "malformed token", authorization: "Bearer not-a-jwt", wantErr: true},
+		{name: "expired", authorization: "Bearer " + sign(t, jose.RS256, key, expired), wantErr: true},
+		{name: "no expiration", authorization: "Bearer " + sign(t, jose.RS256, key, noExpiry), wantErr: true},
+		{name: "wrong audience", authorization: "Bearer " + sign(t, jose.RS256, key, wrongAudience), wantErr: true},
+		{name: "wrong issuer", authorization: "Bearer " + sign(t, jose.RS256, key, wrongIssuer), wantErr: true},
+		{name: "bad signature", authorization: "Bearer " + sign(t, jose.RS256, otherKey, validClaims()), wantErr: true},
+		{name: "symmetric algorithm", authorization: "Bearer " + sign(t, jose.HS256, []byte("0123456789abcdef0123456789abcdef"), validClaims()), wantErr: true},
+		{name: "algorithm not matching the key", authorization: "Bearer " + sign(t, jose.PS256, key, validClaims()), wantErr: true},
+	}
+
+	for source, v := range validators {
+		for _, tt := range tests {
+			t.Run(source+"/"+tt.name, func(t *testing.T) {
+				claims, err := v.Validate(tt.authorization)
+				if tt.wantErr {
+					var unauthorized *UnauthorizedError
+					if !errors.As(err, &unauthorized) {
+						t.Fatalf("expected an UnauthorizedError, got %v", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if claims["sub"] != "vk" {
+					t.Errorf("expected subject vk, got %v", claims["sub"])
+				}
+			})
+		}
+	}
+}
+
+func TestValidateDiscovery(t *testing.T) {
+	key := generateKey(t)
+	srv := serveJWKS(t, key)
+
+	// the issuer is the test server, so that its discovery document is used to find the keys
+	v := newTestValidator(t, commonIL.OIDCConfig{Issuer: srv.URL})
+	claims := validClaims()
+	claims.Issuer = srv.URL
+	_, err := v.Validate("Bearer " + sign(t, jose.RS256, key, claims))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	key := generateKey(t)
+	jwksFile := writeJWKS(t, key)
+
+	noGroups := validClaims()
+	noGroups.Groups = nil
+	otherSite := validClaims()
+	otherSite.Site = "site-b"
+
+	tests := []struct {
+		name          string
+		config        commonIL.OIDCConfig
+		claims        testClaims
+		wantForbidden bool
+	}{
+		{name: "no allow-list", claims: validClaims()},
+		{name: "allowed group", config: commonIL.OIDCConfig{Groups: []string{"admins", "interlink-users"}}, claims: validClaims()},
+		{name: "no allowed group", config: commonIL.OIDCConfig{Groups: []string{"admins"}}, claims: validClaims(), wantForbidden: true},
+		{name: "no groups claim", config: commonIL.OIDCConfig{Groups: []string{"interlink-users"}}, claims: noGroups, wantForbidden: true},
+		{name: "custom group claim", config: commonIL.OIDCConfig{Groups: []string{"site-a"}, GroupClaim: "site"}, claims: validClaims()},
+		{name: "allowed claim", config: commonIL.OIDCConfig{Claims: map[string][]string{"site": {"site-a", "site-c"}}}, claims: validClaims()},
+		{name: "claim not allowed", config: commonIL.OIDCConfig{Claims: map[string][]string{"site": {"site-a"}}}, claims: otherSite, wantForbidden: true},
+		{name: "missing claim", config: commonIL.OIDCConfig{Claims: map[string][]string{"project": {"p1"}}}, claims: validClaims(), wantForbidden: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.Issuer = testIssuer
+			config.JWKSFile = jwksFile
+			v := newTestValidator(t, config)
+
+			_, err := v.Validate("Bearer " + sign(t, jose.RS256, key, tt.claims))
+			var forbidden *ForbiddenError
+			if tt.wantForbidden != errors.As(err, &forbidden) {
+				t.Fatalf("expected forbidden %v, got %v", tt.wantForbidden, err)
+			}
+			if !tt.wantForbidden && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	key := generateKey(t)
+	v := newTestValidator(t, commonIL.OIDCConfig{Issuer: testIssuer, JWKSFile: writeJWKS(t, key), Groups: []string{"admins"}})
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	admin := validClaims()
+	admin.Groups = []string{"admins"}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "authorized", authorization: "Bearer " + sign(t, jose.RS256, key, admin), wantStatus: http.StatusOK},
+		{name: "missing token", wantStatus: http.StatusUnauthorized},
+		{name: "not in an allowed group", authorization: "Bearer " + sign(t, jose.RS256, key, validClaims()), wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/pinglink", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("expected a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestNewValidatorWithoutKeySource(t *testing.T) {
+	_, err := NewValidator(context.Background(), commonIL.OIDCConfig{Audience: testAudience}, nil)
+	if err == nil {
+		t.Fatal("expected an error without Issuer, JWKSURL or JWKSFile")
+	}
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Authenticator adds credentials to a request before it is sent to InterLink
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc is an adapter to use ordinary functions as Authenticators
+type AuthenticatorFunc func(req *http.Request) error
+
+// Authenticate calls f(req)
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// BearerToken returns an Authenticator setting a static bearer token on every request
+func BearerToken(token string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// BearerTokenFile returns an Authenticator reading the bearer token from path on every request,
+// so that tokens refreshed on disk are picked up without restarting the client.
+func BearerTokenFile(path string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		token, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+		return nil
+	})
+}
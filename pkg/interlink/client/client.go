@@ -0,0 +1,507 @@
+// Package client implements a typed Go client for the InterLink REST API.
+// It is used by the Virtual Kubelet and can be reused by any tool that needs to talk to an InterLink instance.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	v1 "k8s.io/api/core/v1"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// InterLink API routes. The client only uses the versioned /api/v1 routes: the legacy flat ones are served by InterLink as aliases.
+const (
+	PodsRoute        = "/api/v1/pods"
+	BatchCreateRoute = "/api/v1/pods/batch"
+	StatusRoute      = "/api/v1/pods/status"
+	StatsRoute       = "/api/v1/pods/stats"
+	CacheRoute       = "/api/v1/cache"
+	PingRoute        = "/api/v1/ping"
+	OperationsRoute  = "/api/v1/operations"
+	ResourcesRoute   = "/api/v1/resources"
+)
+
+// podRoute returns the route of the Pod namespace/name, followed by the given sub-resources, if any
+func podRoute(namespace string, name string, subresources ...string) string {
+	route := PodsRoute + "/" + url.PathEscape(namespace) + "/" + url.PathEscape(name)
+	for _, subresource := range subresources {
+		route += "/" + subresource
+	}
+	return route
+}
+
+// Client is a client for the InterLink REST API. It is safe for concurrent use.
+type Client struct {
+	endpoint   string
+	httpClient *http.Client
+	tlsConfig  *tls.Config
+	auth       Authenticator
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the http.Client used to perform requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport sets the http.RoundTripper used to perform requests
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient = &http.Client{Transport: transport}
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to verify InterLink and, for mutual TLS, to present a client certificate.
+// It applies to the default transport, TCP or Unix domain socket, and is ignored if a custom HTTP client or transport is provided.
+// Over a Unix domain socket, InterLink is verified as tlsConfig.ServerName, by default commonIL.UnixSocketServerName.
+// It has no effect on http:// endpoints.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = tlsConfig
+	}
+}
+
+// WithAuthenticator sets the Authenticator used to add credentials to every request
+func WithAuthenticator(auth Authenticator) Option {
+	return func(c *Client) {
+		c.auth = auth
+	}
+}
+
+// Endpoint builds the InterLink endpoint from an URL and a port, the same way it is expressed in the configuration files.
+// unix:// URLs are returned as they are, while the port is appended to http:// and https:// URLs.
+func Endpoint(url string, port string) (string, error) {
+	if commonIL.IsUnixSocket(url) {
+		return url, nil
+	} else if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return url + ":" + port, nil
+	}
+	return "", fmt.Errorf("InterLink URL %q should either start per unix://, http:// or https://", url)
+}
+
+// NewClient returns a Client performing requests against the InterLink instance listening on endpoint.
+// By default requests are performed by http.DefaultClient without any credential.
+// If endpoint is a unix:// URL, requests are dialed to that socket unless a custom HTTP client or transport is provided,
+// over TLS if a TLS configuration is set.
+func NewClient(endpoint string, opts ...Option) *Client {
+	c := &Client{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	unixSocket := commonIL.IsUnixSocket(endpoint)
+	if c.httpClient == http.DefaultClient && (unixSocket || c.tlsConfig != nil) {
+		var transport *http.Transport
+		tlsConfig := c.tlsConfig
+		if unixSocket {
+			transport = commonIL.UnixSocketTransport(commonIL.UnixSocketPath(endpoint))
+			if tlsConfig != nil {
+				tlsConfig = commonIL.UnixSocketTLSConfig(tlsConfig)
+			}
+		} else {
+			transport = http.DefaultTransport.(*http.Transport).Clone()
+		}
+		transport.TLSClientConfig = tlsConfig
+		c.httpClient = &http.Client{Transport: transport}
+	}
+	if unixSocket {
+		c.endpoint = commonIL.UnixSocketHost
+		if c.tlsConfig != nil {
+			c.endpoint = commonIL.UnixSocketTLSHost
+		}
+	}
+	return c
+}
+
+// Endpoint returns the InterLink endpoint the client is talking to
+func (c *Client) Endpoint() string {
+	return c.endpoint
+}
+
+// do performs a request against the given route, checks the returned status code and returns the response.
+// The caller is responsible for closing the response body.
+func (c *Client) do(ctx context.Context, method string, route string, body io.Reader) (*http.Response, error) {
+	return c.doWithHeader(ctx, method, route, body, nil)
+}
+
+// doWithHeader performs the request like do, adding header to the request headers
+func (c *Client) doWithHeader(ctx context.Context, method string, route string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+route, body)
+	if err != nil {
+		return nil, &RequestError{Route: route, Err: err}
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// continue the caller trace in InterLink, when a propagator is registered
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	if c.auth != nil {
+		err = c.auth.Authenticate(req)
+		if err != nil {
+			return nil, &RequestError{Route: route, Err: err}
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, &RequestError{Route: route, Err: err}
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		defer resp.Body.Close()
+		returnValue, _ := io.ReadAll(resp.Body)
+		return nil, newStatusError(route, resp.StatusCode, returnValue)
+	}
+
+	return resp, nil
+}
+
+// doJSON marshals in, performs the request and returns the whole response body
+func (c *Client) doJSON(ctx context.Context, method string, route string, in interface{}) ([]byte, error) {
+	bodyBytes, err := json.Marshal(in)
+	if err != nil {
+		return nil, &RequestError{Route: route, Err: err}
+	}
+
+	resp, err := c.do(ctx, method, route, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	returnValue, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &RequestError{Route: route, Err: err}
+	}
+	return returnValue, nil
+}
+
+// Create asks InterLink to create the Pod, along with its already retrieved ConfigMaps and Secrets, on the sidecar.
+// It returns the raw response forwarded by InterLink from the sidecar.
+func (c *Client) Create(ctx context.Context, pod commonIL.PodCreateRequests) ([]byte, error) {
+	return c.doJSON(ctx, http.MethodPost, PodsRoute, pod)
+}
+
+// CreateBatch asks InterLink to create many Pods in a single call, and returns the outcome of every Pod in the request order.
+// A nil error with some failed results means that only part of the Pods have been created.
+func (c *Client) CreateBatch(ctx context.Context, pods []commonIL.PodCreateRequests) ([]commonIL.PodCreateResult, error) {
+	returnValue, err := c.doJSON(ctx, http.MethodPost, BatchCreateRoute, pods)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []commonIL.PodCreateResult
+	err = json.Unmarshal(returnValue, &results)
+	if err != nil {
+		return nil, &DecodeError{Route: BatchCreateRoute, Body: returnValue, Err: err}
+	}
+	if len(results) != len(pods) {
+		return nil, &DecodeError{Route: BatchCreateRoute, Body: returnValue, Err: fmt.Errorf("expected %d results, got %d", len(pods), len(results))}
+	}
+	return results, nil
+}
+
+// Delete asks InterLink to delete the Pod from the sidecar and returns the statuses of the deleted Pods.
+func (c *Client) Delete(ctx context.Context, pod *v1.Pod) ([]commonIL.PodStatus, error) {
+	route := podRoute(pod.Namespace, pod.Name)
+	returnValue, err := c.doJSON(ctx, http.MethodDelete, route, pod)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []commonIL.PodStatus
+	err = json.Unmarshal(returnValue, &statuses)
+	if err != nil {
+		return nil, &DecodeError{Route: route, Body: returnValue, Err: err}
+	}
+	return statuses, nil
+}
+
+// Status asks InterLink for the status of the provided Pods.
+// An empty list returns every status cached by InterLink.
+func (c *Client) Status(ctx context.Context, pods []*v1.Pod) ([]commonIL.PodStatus, error) {
+	if pods == nil {
+		pods = []*v1.Pod{}
+	}
+	returnValue, err := c.doJSON(ctx, http.MethodPost, StatusRoute, pods)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []commonIL.PodStatus
+	err = json.Unmarshal(returnValue, &statuses)
+	if err != nil {
+		return nil, &DecodeError{Route: StatusRoute, Body: returnValue, Err: err}
+	}
+	return statuses, nil
+}
+
+// Stats asks InterLink for the resource usage of the provided Pods.
+// The Pods whose sidecar does not report stats are missing from the returned list.
+func (c *Client) Stats(ctx context.Context, pods []*v1.Pod) ([]commonIL.PodStats, error) {
+	returnValue, err := c.doJSON(ctx, http.MethodPost, StatsRoute, pods)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []commonIL.PodStats
+	err = json.Unmarshal(returnValue, &stats)
+	if err != nil {
+		return nil, &DecodeError{Route: StatsRoute, Body: returnValue, Err: err}
+	}
+	return stats, nil
+}
+
+// Resources asks InterLink for the resources of the remote site, summed over every sidecar reporting them.
+// A StatusError with code 501 means that no sidecar reports its resources.
+func (c *Client) Resources(ctx context.Context) (commonIL.NodeResources, error) {
+	var resources commonIL.NodeResources
+	resp, err := c.do(ctx, http.MethodGet, ResourcesRoute, nil)
+	if err != nil {
+		return resources, err
+	}
+	defer resp.Body.Close()
+
+	returnValue, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resources, &RequestError{Route: ResourcesRoute, Err: err}
+	}
+	err = json.Unmarshal(returnValue, &resources)
+	if err != nil {
+		return resources, &DecodeError{Route: ResourcesRoute, Body: returnValue, Err: err}
+	}
+	return resources, nil
+}
+
+// GetLogs asks InterLink for the logs of a container. The caller is responsible for closing the returned stream.
+func (c *Client) GetLogs(ctx context.Context, logsRequest commonIL.LogStruct) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("container", logsRequest.ContainerName)
+	if logsRequest.PodUID != "" {
+		query.Set("uid", logsRequest.PodUID)
+	}
+	opts := logsRequest.Opts
+	if opts.Tail != 0 {
+		query.Set("tailLines", strconv.Itoa(opts.Tail))
+	}
+	if opts.LimitBytes != 0 {
+		query.Set("limitBytes", strconv.Itoa(opts.LimitBytes))
+	}
+	if opts.SinceSeconds != 0 {
+		query.Set("sinceSeconds", strconv.Itoa(opts.SinceSeconds))
+	}
+	if !opts.SinceTime.IsZero() {
+		query.Set("sinceTime", opts.SinceTime.Format(time.RFC3339))
+	}
+	for name, value := range map[string]bool{"timestamps": opts.Timestamps, "follow": opts.Follow, "previous": opts.Previous} {
+		if value {
+			query.Set(name, "true")
+		}
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, podRoute(logsRequest.Namespace, logsRequest.PodName, "logs")+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Ping pings the InterLink API and returns the value it answers with.
+// 0 means that InterLink has KUBECONFIG set, 1 that it has not.
+func (c *Client) Ping(ctx context.Context) (int, error) {
+	resp, err := c.do(ctx, http.MethodGet, PingRoute, nil)
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+
+	returnValue, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return -1, &RequestError{Route: PingRoute, Err: err}
+	}
+
+	retVal, err := strconv.Atoi(string(returnValue))
+	if err != nil {
+		return -1, &DecodeError{Route: PingRoute, Body: returnValue, Err: err}
+	}
+	return retVal, nil
+}
+
+// UpdateCache asks InterLink to drop the Pod identified by uid from its status cache
+func (c *Client) UpdateCache(ctx context.Context, uid string) error {
+	resp, err := c.do(ctx, http.MethodDelete, CacheRoute+"/"+url.PathEscape(uid), nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// SubmitCreate asks InterLink to create the Pod in the background and returns the operation tracking the creation.
+// Submits repeated with the same idempotencyKey, e.g. after a network error, return the same operation instead of creating the Pod twice.
+func (c *Client) SubmitCreate(ctx context.Context, pod commonIL.PodCreateRequests, idempotencyKey string) (*commonIL.Operation, error) {
+	return c.submitOperation(ctx, OperationsRoute+"/create", pod, idempotencyKey)
+}
+
+// SubmitCreateBatch asks InterLink to create many Pods in the background with a single call, and returns the operation of every Pod
+// in the request order. Every Pod carries its own idempotency key, with the same meaning as for SubmitCreate.
+func (c *Client) SubmitCreateBatch(ctx context.Context, pods []commonIL.CreateOperationRequest) ([]commonIL.Operation, error) {
+	route := OperationsRoute + "/create/batch"
+	returnValue, err := c.doJSON(ctx, http.MethodPost, route, pods)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []commonIL.Operation
+	err = json.Unmarshal(returnValue, &ops)
+	if err != nil {
+		return nil, &DecodeError{Route: route, Body: returnValue, Err: err}
+	}
+	if len(ops) != len(pods) {
+		return nil, &DecodeError{Route: route, Body: returnValue, Err: fmt.Errorf("expected %d operations, got %d", len(pods), len(ops))}
+	}
+	return ops, nil
+}
+
+// SubmitDelete asks InterLink to delete the Pod in the background and returns the operation tracking the deletion.
+// Submits repeated with the same idempotencyKey return the same operation.
+func (c *Client) SubmitDelete(ctx context.Context, pod *v1.Pod, idempotencyKey string) (*commonIL.Operation, error) {
+	return c.submitOperation(ctx, OperationsRoute+"/delete", pod, idempotencyKey)
+}
+
+func (c *Client) submitOperation(ctx context.Context, route string, in interface{}, idempotencyKey string) (*commonIL.Operation, error) {
+	bodyBytes, err := json.Marshal(in)
+	if err != nil {
+		return nil, &RequestError{Route: route, Err: err}
+	}
+
+	header := http.Header{}
+	if idempotencyKey != "" {
+		header.Set("Idempotency-Key", idempotencyKey)
+	}
+	resp, err := c.doWithHeader(ctx, http.MethodPost, route, bytes.NewReader(bodyBytes), header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return decodeOperation(route, resp.Body)
+}
+
+// Operation returns the operation identified by id
+func (c *Client) Operation(ctx context.Context, id string) (*commonIL.Operation, error) {
+	route := OperationsRoute + "/" + url.PathEscape(id)
+	resp, err := c.do(ctx, http.MethodGet, route, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return decodeOperation(route, resp.Body)
+}
+
+// WaitOperation polls the operation identified by id every interval until it is finished, or until ctx is done.
+// A failed operation is returned along with an *OperationError.
+func (c *Client) WaitOperation(ctx context.Context, id string, interval time.Duration) (*commonIL.Operation, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		op, err := c.Operation(ctx, id)
+		if err == nil && op.State == commonIL.OperationFailed {
+			return op, &OperationError{Operation: *op}
+		} else if err != nil || op.Finished() {
+			return op, err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return op, ctx.Err()
+		}
+	}
+}
+
+func decodeOperation(route string, body io.Reader) (*commonIL.Operation, error) {
+	returnValue, err := io.ReadAll(body)
+	if err != nil {
+		return nil, &RequestError{Route: route, Err: err}
+	}
+
+	var op commonIL.Operation
+	err = json.Unmarshal(returnValue, &op)
+	if err != nil {
+		return nil, &DecodeError{Route: route, Body: returnValue, Err: err}
+	}
+	return &op, nil
+}
+
+// Exec runs a command in a container through InterLink and returns the websocket stream of the session.
+// Stdin and resize events are sent on the stream, while stdout, stderr and the final commonIL.ExecResult are read from it.
+// The caller is responsible for closing the stream.
+func (c *Client) Exec(ctx context.Context, req commonIL.ExecRequest) (*commonIL.StreamConn, error) {
+	return c.openStream(ctx, req.Namespace, req.PodName, "exec", req.Query())
+}
+
+// Attach attaches to the main process of a container through InterLink and returns the websocket stream of the session, as Exec does
+func (c *Client) Attach(ctx context.Context, req commonIL.ExecRequest) (*commonIL.StreamConn, error) {
+	return c.openStream(ctx, req.Namespace, req.PodName, "attach", req.Query())
+}
+
+// PortForward opens a connection to a port of the Pod through InterLink and returns its websocket stream,
+// to be wrapped with commonIL.NewPortForwardStream. Every forwarded connection needs its own stream.
+// The caller is responsible for closing the stream.
+func (c *Client) PortForward(ctx context.Context, req commonIL.PortForwardRequest) (*commonIL.StreamConn, error) {
+	return c.openStream(ctx, req.Namespace, req.PodName, "portforward", req.Query())
+}
+
+func (c *Client) openStream(ctx context.Context, namespace string, podName string, action string, query url.Values) (*commonIL.StreamConn, error) {
+	route := podRoute(namespace, podName, action)
+
+	// the credentials and the trace context are set on a regular request, whose headers are then sent with the websocket handshake
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+route, nil)
+	if err != nil {
+		return nil, &RequestError{Route: route, Err: err}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	if c.auth != nil {
+		err = c.auth.Authenticate(req)
+		if err != nil {
+			return nil, &RequestError{Route: route, Err: err}
+		}
+	}
+
+	// the websocket is dialed the same way as the other calls, e.g. through the same Unix domain socket or TLS configuration
+	transport, _ := c.httpClient.Transport.(*http.Transport)
+	conn, resp, err := commonIL.WebsocketDialer(transport).DialContext(ctx, commonIL.WebsocketURL(c.endpoint)+route+"?"+query.Encode(), req.Header)
+	if err != nil {
+		if resp != nil {
+			defer resp.Body.Close()
+			returnValue, _ := io.ReadAll(resp.Body)
+			return nil, newStatusError(route, resp.StatusCode, returnValue)
+		}
+		return nil, &RequestError{Route: route, Err: err}
+	}
+	return commonIL.NewStreamConn(conn), nil
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// RequestError is returned when a request could not be built or performed, or its response could not be read
+type RequestError struct {
+	Route string
+	Err   error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("InterLink %s request failed: %v", e.Route, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is returned when InterLink answers with a status code different from 200 OK.
+// APIError holds the decoded error body, if InterLink answered with one.
+type StatusError struct {
+	Route      string
+	StatusCode int
+	Body       []byte
+	APIError   *commonIL.Error
+}
+
+func newStatusError(route string, statusCode int, body []byte) *StatusError {
+	statusErr := &StatusError{Route: route, StatusCode: statusCode, Body: body}
+	var apiErr commonIL.Error
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+		statusErr.APIError = &apiErr
+	}
+	return statusErr
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("unexpected status code %d from InterLink %s", e.StatusCode, e.Route)
+	if e.APIError != nil {
+		msg += ": " + e.APIError.Error()
+	} else if len(e.Body) > 0 {
+		msg += ": " + string(e.Body)
+	}
+	return msg
+}
+
+// Reason returns the failure reason reported by InterLink, including the sidecar message, or the raw response body
+func (e *StatusError) Reason() string {
+	if e.APIError != nil {
+		return e.APIError.Error()
+	}
+	if len(e.Body) > 0 {
+		return string(e.Body)
+	}
+	return http.StatusText(e.StatusCode)
+}
+
+// DecodeError is returned when the InterLink response can't be decoded
+type DecodeError struct {
+	Route string
+	Body  []byte
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("unable to decode InterLink %s response: %v", e.Route, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// OperationError is returned for an asynchronous operation that failed. Operation.OutcomeUnknown tells whether InterLink
+// could not find out if the sidecar applied it anyway.
+type OperationError struct {
+	Operation commonIL.Operation
+}
+
+func (e *OperationError) Error() string {
+	msg := fmt.Sprintf("InterLink %s operation %s failed", e.Operation.Type, e.Operation.ID)
+	if e.Operation.Error != nil {
+		msg += ": " + e.Operation.Error.Error()
+	}
+	return msg
+}
+
+func (e *OperationError) Unwrap() error {
+	if e.Operation.Error == nil {
+		return nil
+	}
+	return e.Operation.Error
+}
+
+// IsStatus reports whether err is a StatusError with the given status code
+func IsStatus(err error, statusCode int) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == statusCode
+}
+
+// IsNotFound reports whether err is a StatusError with a 404 status code
+func IsNotFound(err error) bool {
+	return IsStatus(err, http.StatusNotFound)
+}
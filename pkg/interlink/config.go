@@ -3,7 +3,9 @@ package interlink
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/containerd/containerd/log"
 	"gopkg.in/yaml.v2"
@@ -11,14 +13,98 @@ import (
 
 // InterLinkConfig holds the whole configuration
 type InterLinkConfig struct {
-	InterlinkAddress  string `yaml:"InterlinkAddress"`
-	Interlinkport     string `yaml:"InterlinkPort"`
-	Sidecarurl        string `yaml:"SidecarURL"`
-	Sidecarport       string `yaml:"SidecarPort"`
-	ExportPodData     bool   `yaml:"ExportPodData"`
-	VerboseLogging    bool   `yaml:"VerboseLogging"`
-	ErrorsOnlyLogging bool   `yaml:"ErrorsOnlyLogging"`
-	DataRootFolder    string `yaml:"DataRootFolder"`
+	InterlinkAddress  string              `yaml:"InterlinkAddress"`
+	Interlinkport     string              `yaml:"InterlinkPort"`
+	Sidecarurl        string              `yaml:"SidecarURL"`
+	Sidecarport       string              `yaml:"SidecarPort"`
+	ExportPodData     bool                `yaml:"ExportPodData"`
+	VerboseLogging    bool                `yaml:"VerboseLogging"`
+	ErrorsOnlyLogging bool                `yaml:"ErrorsOnlyLogging"`
+	DataRootFolder    string              `yaml:"DataRootFolder"`
+	SocketMode        string              `yaml:"SocketMode"`
+	SocketOwner       string              `yaml:"SocketOwner"`
+	SocketGroup       string              `yaml:"SocketGroup"`
+	TLS               TLSConfig           `yaml:"TLS"`
+	SidecarTLS        TLSConfig           `yaml:"SidecarTLS"`
+	OIDC              OIDCConfig          `yaml:"OIDC"`
+	Sidecars          []SidecarConfig     `yaml:"Sidecars"`
+	SidecarRoutes     []SidecarRoute      `yaml:"SidecarRoutes"`
+	DefaultSidecar    string              `yaml:"DefaultSidecar"`
+	MetricsAddress    string              `yaml:"MetricsAddress"`
+	AdminAddress      string              `yaml:"AdminAddress"`
+	Tracing           TracingConfig       `yaml:"Tracing"`
+	ShutdownTimeout   string              `yaml:"ShutdownTimeout"`
+	SidecarClient     SidecarClientConfig `yaml:"SidecarClient"`
+}
+
+// SidecarConfig describes a named sidecar InterLink can forward Pods to.
+// When no sidecar is listed, a single "default" sidecar is built from SidecarURL, SidecarPort and SidecarTLS.
+type SidecarConfig struct {
+	Name string    `yaml:"Name"`
+	URL  string    `yaml:"URL"`
+	Port string    `yaml:"Port"`
+	TLS  TLSConfig `yaml:"TLS"`
+}
+
+// SidecarClientConfig tunes the calls InterLink makes to the sidecars. Durations are expressed as Go durations (e.g. "30s").
+// Timeouts maps a sidecar route (e.g. "/status") to the time the sidecar has to answer it; for "/getLogs" it only bounds
+// the time to the first byte, so that followed logs are never cut. Idempotent calls (status, logs and delete) are retried
+// up to MaxRetries times with exponential backoff and jitter. After BreakerThreshold consecutive failures, the calls to a
+// sidecar fail fast for BreakerCooldown and the sidecar is reported unhealthy. Zero values pick the defaults, while
+// negative MaxRetries or BreakerThreshold disable retries or circuit breaking.
+type SidecarClientConfig struct {
+	Timeouts         map[string]string `yaml:"Timeouts"`
+	DefaultTimeout   string            `yaml:"DefaultTimeout"`
+	MaxRetries       int               `yaml:"MaxRetries"`
+	RetryBackoff     string            `yaml:"RetryBackoff"`
+	MaxRetryBackoff  string            `yaml:"MaxRetryBackoff"`
+	BreakerThreshold int               `yaml:"BreakerThreshold"`
+	BreakerCooldown  string            `yaml:"BreakerCooldown"`
+}
+
+// SidecarRoute sends to Sidecar the Pods matching every condition set in the route.
+// Annotations and NodeSelector entries must all be present on the Pod, RuntimeClassName must be equal,
+// and at least one of the Resources must be requested by a container.
+type SidecarRoute struct {
+	Sidecar          string            `yaml:"Sidecar"`
+	Annotations      map[string]string `yaml:"Annotations"`
+	RuntimeClassName string            `yaml:"RuntimeClassName"`
+	NodeSelector     map[string]string `yaml:"NodeSelector"`
+	Resources        []string          `yaml:"Resources"`
+}
+
+// OIDCConfig holds the options to validate the bearer tokens sent to the InterLink API.
+// Keys are fetched from the Issuer discovery document, from JWKSURL or from the local JWKSFile.
+// If Groups is not empty, the GroupClaim (default "groups") must contain at least one of them.
+// Every entry in Claims is an allow-list: the token claim must match at least one of the listed values.
+type OIDCConfig struct {
+	Issuer     string              `yaml:"Issuer"`
+	JWKSURL    string              `yaml:"JWKSURL"`
+	JWKSFile   string              `yaml:"JWKSFile"`
+	Audience   string              `yaml:"Audience"`
+	Groups     []string            `yaml:"Groups"`
+	GroupClaim string              `yaml:"GroupClaim"`
+	Claims     map[string][]string `yaml:"Claims"`
+}
+
+// Enabled returns true if a source for the token signing keys has been set
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != "" || c.JWKSURL != "" || c.JWKSFile != ""
+}
+
+// TracingConfig holds the options of the OTLP exporter InterLink sends its spans to.
+// Endpoint is the host:port of an OTLP gRPC collector; tracing is disabled when it is empty.
+type TracingConfig struct {
+	Endpoint    string            `yaml:"Endpoint"`
+	Insecure    bool              `yaml:"Insecure"`
+	Headers     map[string]string `yaml:"Headers"`
+	ServiceName string            `yaml:"ServiceName"`
+	SampleRatio *float64          `yaml:"SampleRatio"`
+}
+
+// Enabled returns true if an OTLP endpoint has been set
+func (c TracingConfig) Enabled() bool {
+	return c.Endpoint != ""
 }
 
 // NewInterLinkConfig returns a variable of type InterLinkConfig, used in many other functions and the first encountered error.
@@ -78,3 +164,18 @@ func NewInterLinkConfig() (InterLinkConfig, error) {
 
 	return interLinkNewConfig, nil
 }
+
+// DefaultShutdownTimeout is how long InterLink waits for in-flight calls on shutdown when ShutdownTimeout is not set
+const DefaultShutdownTimeout = 30 * time.Second
+
+// ParseShutdownTimeout parses ShutdownTimeout as a duration (e.g. "45s"). An empty string returns DefaultShutdownTimeout.
+func (c InterLinkConfig) ParseShutdownTimeout() (time.Duration, error) {
+	if c.ShutdownTimeout == "" {
+		return DefaultShutdownTimeout, nil
+	}
+	timeout, err := time.ParseDuration(c.ShutdownTimeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ShutdownTimeout %q: %w", c.ShutdownTimeout, err)
+	}
+	return timeout, nil
+}
@@ -0,0 +1,204 @@
+package interlink
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// StreamProtocol is the websocket subprotocol of exec, attach and port forward streams, between the VK and InterLink and between InterLink and the sidecars.
+// Every binary message starts with the byte of the channel it belongs to, followed by the payload.
+const StreamProtocol = "v1.stream.interlink.eu"
+
+// Channels of the exec and attach streams
+const (
+	// StreamStdin carries the stdin of the command, from the VK to the sidecar
+	StreamStdin byte = 0
+	// StreamStdout carries the stdout of the command, from the sidecar to the VK
+	StreamStdout byte = 1
+	// StreamStderr carries the stderr of the command, from the sidecar to the VK
+	StreamStderr byte = 2
+	// StreamStatus carries the ExecResult sent by the sidecar once the command is over
+	StreamStatus byte = 3
+	// StreamResize carries the TermSize of the terminal, from the VK to the sidecar
+	StreamResize byte = 4
+	// StreamClose tells that the channel in the payload is closed, e.g. the end of stdin
+	StreamClose byte = 5
+)
+
+// ExecRequest describes the command to run in a container, or the container to attach to when Command is empty
+type ExecRequest struct {
+	Namespace     string   `json:"namespace"`
+	PodName       string   `json:"name"`
+	PodUID        string   `json:"UID"`
+	ContainerName string   `json:"container"`
+	Command       []string `json:"command,omitempty"`
+	Stdin         bool     `json:"stdin"`
+	Stdout        bool     `json:"stdout"`
+	Stderr        bool     `json:"stderr"`
+	TTY           bool     `json:"tty"`
+}
+
+// Query encodes the request in the query parameters of an exec or attach call, named after the kubelet ones
+func (r ExecRequest) Query() url.Values {
+	query := url.Values{}
+	query.Set("namespace", r.Namespace)
+	query.Set("name", r.PodName)
+	query.Set("uid", r.PodUID)
+	query.Set("container", r.ContainerName)
+	for _, arg := range r.Command {
+		query.Add("command", arg)
+	}
+	query.Set("input", strconv.FormatBool(r.Stdin))
+	query.Set("output", strconv.FormatBool(r.Stdout))
+	query.Set("error", strconv.FormatBool(r.Stderr))
+	query.Set("tty", strconv.FormatBool(r.TTY))
+	return query
+}
+
+// ParseExecRequest decodes a request encoded by ExecRequest.Query
+func ParseExecRequest(query url.Values) (ExecRequest, error) {
+	req := ExecRequest{
+		Namespace:     query.Get("namespace"),
+		PodName:       query.Get("name"),
+		PodUID:        query.Get("uid"),
+		ContainerName: query.Get("container"),
+		Command:       query["command"],
+	}
+
+	var err error
+	parseBool := func(name string, out *bool) {
+		if value := query.Get(name); value != "" && err == nil {
+			*out, err = strconv.ParseBool(value)
+		}
+	}
+	parseBool("input", &req.Stdin)
+	parseBool("output", &req.Stdout)
+	parseBool("error", &req.Stderr)
+	parseBool("tty", &req.TTY)
+	if err != nil {
+		return req, fmt.Errorf("invalid exec options: %w", err)
+	}
+	if req.Namespace == "" || req.PodName == "" {
+		return req, errors.New("the namespace and the name of the Pod are needed")
+	}
+	return req, nil
+}
+
+// ExecResult is sent on StreamStatus once the command is over. ExitCode is meaningful only if Error is empty.
+type ExecResult struct {
+	ExitCode int    `json:"exitCode"`
+	Error    string `json:"error,omitempty"`
+}
+
+// TermSize is sent on StreamResize whenever the terminal of the user is resized
+type TermSize struct {
+	Width  uint16 `json:"width"`
+	Height uint16 `json:"height"`
+}
+
+// StreamConn multiplexes the channels of an exec, attach or port forward stream over a websocket connection. It is safe for concurrent writes.
+type StreamConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+// NewStreamConn wraps an established websocket connection
+func NewStreamConn(conn *websocket.Conn) *StreamConn {
+	return &StreamConn{conn: conn}
+}
+
+// WriteFrame sends payload on channel
+func (c *StreamConn) WriteFrame(channel byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.conn.WriteMessage(websocket.BinaryMessage, append([]byte{channel}, payload...))
+}
+
+// WriteJSON sends v, encoded as JSON, on channel
+func (c *StreamConn) WriteJSON(channel byte, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteFrame(channel, payload)
+}
+
+// ReadFrame returns the next message with its channel. It returns io.EOF once the peer closed the stream normally.
+func (c *StreamConn) ReadFrame() (byte, []byte, error) {
+	for {
+		messageType, message, err := c.conn.ReadMessage()
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			return 0, nil, io.EOF
+		} else if err != nil {
+			return 0, nil, err
+		}
+		if messageType != websocket.BinaryMessage || len(message) == 0 {
+			continue
+		}
+		return message[0], message[1:], nil
+	}
+}
+
+// Writer returns a writer sending on channel. Closing it sends StreamClose for the channel.
+func (c *StreamConn) Writer(channel byte) io.WriteCloser {
+	return &streamWriter{conn: c, channel: channel}
+}
+
+// Close tells the peer that the stream is over and closes the connection
+func (c *StreamConn) Close() error {
+	c.writeMu.Lock()
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+	return c.conn.Close()
+}
+
+type streamWriter struct {
+	conn    *StreamConn
+	channel byte
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	err := w.conn.WriteFrame(w.channel, p)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *streamWriter) Close() error {
+	return w.conn.WriteFrame(StreamClose, []byte{w.channel})
+}
+
+// WebsocketDialer returns a websocket dialer connecting the same way as transport, i.e. through the same Unix domain socket,
+// TLS configuration and proxy. A nil transport returns a dialer connecting like http.DefaultTransport.
+func WebsocketDialer(transport *http.Transport) *websocket.Dialer {
+	if transport == nil {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+	return &websocket.Dialer{
+		NetDialContext:   transport.DialContext,
+		TLSClientConfig:  transport.TLSClientConfig,
+		Proxy:            transport.Proxy,
+		HandshakeTimeout: 30 * time.Second,
+		Subprotocols:     []string{StreamProtocol},
+	}
+}
+
+// WebsocketURL turns an http:// or https:// endpoint into the matching ws:// or wss:// one
+func WebsocketURL(endpoint string) string {
+	if strings.HasPrefix(endpoint, "https://") {
+		return "wss://" + strings.TrimPrefix(endpoint, "https://")
+	}
+	return "ws://" + strings.TrimPrefix(endpoint, "http://")
+}
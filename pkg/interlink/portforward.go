@@ -0,0 +1,103 @@
+package interlink
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+)
+
+// Channels of the port forward streams. StreamClose, with PortForwardData in the payload, half-closes the forwarded connection.
+const (
+	// PortForwardData carries the bytes of the forwarded connection, both ways
+	PortForwardData byte = 0
+	// PortForwardError carries, as plain text, the error that interrupted the forwarded connection on the sidecar
+	PortForwardError byte = 3
+)
+
+// PortForwardRequest describes a connection to forward to a port of a Pod
+type PortForwardRequest struct {
+	Namespace string `json:"namespace"`
+	PodName   string `json:"name"`
+	PodUID    string `json:"UID"`
+	Port      int32  `json:"port"`
+}
+
+// Query encodes the request in the query parameters of a port forward call
+func (r PortForwardRequest) Query() url.Values {
+	query := url.Values{}
+	query.Set("namespace", r.Namespace)
+	query.Set("name", r.PodName)
+	query.Set("uid", r.PodUID)
+	query.Set("port", strconv.FormatInt(int64(r.Port), 10))
+	return query
+}
+
+// ParsePortForwardRequest decodes a request encoded by PortForwardRequest.Query
+func ParsePortForwardRequest(query url.Values) (PortForwardRequest, error) {
+	req := PortForwardRequest{
+		Namespace: query.Get("namespace"),
+		PodName:   query.Get("name"),
+		PodUID:    query.Get("uid"),
+	}
+	if req.Namespace == "" || req.PodName == "" {
+		return req, errors.New("the namespace and the name of the Pod are needed")
+	}
+
+	port, err := strconv.ParseInt(query.Get("port"), 10, 32)
+	if err != nil || port <= 0 || port > 65535 {
+		return req, fmt.Errorf("invalid port %q", query.Get("port"))
+	}
+	req.Port = int32(port)
+	return req, nil
+}
+
+// NewPortForwardStream adapts a port forward stream to the forwarded connection. Reads return the data sent by the peer
+// until it half-closes the connection, writes send data and Close half-closes the connection on this side.
+// The websocket itself is closed by closing conn.
+func NewPortForwardStream(conn *StreamConn) io.ReadWriteCloser {
+	return &portForwardStream{conn: conn}
+}
+
+type portForwardStream struct {
+	conn    *StreamConn
+	pending []byte
+	err     error
+}
+
+func (s *portForwardStream) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+
+		channel, payload, err := s.conn.ReadFrame()
+		switch {
+		case err != nil:
+			s.err = err
+		case channel == PortForwardData:
+			s.pending = payload
+		case channel == PortForwardError:
+			s.err = errors.New(string(payload))
+		case channel == StreamClose:
+			s.err = io.EOF
+		}
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *portForwardStream) Write(p []byte) (int, error) {
+	err := s.conn.WriteFrame(PortForwardData, p)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *portForwardStream) Close() error {
+	return s.conn.WriteFrame(StreamClose, []byte{PortForwardData})
+}
@@ -0,0 +1,139 @@
+package interlink
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+const (
+	// UnixSocketPrefix is the scheme identifying Unix domain socket URLs in the configuration files
+	UnixSocketPrefix = "unix://"
+	// UnixSocketHost is the placeholder host used to build request URLs when talking over a Unix domain socket
+	UnixSocketHost = "http://unix"
+	// UnixSocketTLSHost is the placeholder host used to build request URLs when talking TLS over a Unix domain socket
+	UnixSocketTLSHost = "https://unix"
+	// UnixSocketServerName is the name the server certificate is verified against over a Unix domain socket, unless ServerName is set,
+	// since the placeholder host is no real name
+	UnixSocketServerName = "localhost"
+	// DefaultSocketMode is the permission set on Unix domain sockets when SocketMode is not specified
+	DefaultSocketMode = 0660
+)
+
+// IsUnixSocket returns true if the URL points to a Unix domain socket
+func IsUnixSocket(url string) bool {
+	return strings.HasPrefix(url, UnixSocketPrefix)
+}
+
+// UnixSocketPath returns the filesystem path of a unix:// URL
+func UnixSocketPath(url string) string {
+	return strings.TrimPrefix(url, UnixSocketPrefix)
+}
+
+// UnixSocketTLSConfig returns a copy of tlsConfig verifying the server as UnixSocketServerName, if it has no ServerName
+func UnixSocketTLSConfig(tlsConfig *tls.Config) *tls.Config {
+	tlsConfig = tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = UnixSocketServerName
+	}
+	return tlsConfig
+}
+
+// UnixSocketTransport returns a http.Transport dialing every connection to the Unix domain socket at socketPath, whatever the request host is
+func UnixSocketTransport(socketPath string) *http.Transport {
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+}
+
+// ListenUnixSocket binds a Unix domain socket at socketPath, removing a stale socket left by a previous run.
+// The socket file gets the requested permissions and, if not empty, owner and group (either names or numeric IDs).
+func ListenUnixSocket(socketPath string, mode os.FileMode, owner string, group string) (net.Listener, error) {
+	if info, err := os.Stat(socketPath); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s already exists and is not a socket", socketPath)
+		}
+		err = os.Remove(socketPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(socketPath, mode)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	uid, gid, err := lookupOwnership(owner, group)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if uid != -1 || gid != -1 {
+		err = os.Lchown(socketPath, uid, gid)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+
+	return listener, nil
+}
+
+// ParseSocketMode parses an octal permission string (e.g. "0660"). An empty string returns DefaultSocketMode.
+func ParseSocketMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return DefaultSocketMode, nil
+	}
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid socket mode %q: %w", mode, err)
+	}
+	return os.FileMode(parsed), nil
+}
+
+// lookupOwnership resolves owner and group to numeric IDs. Empty values resolve to -1, leaving them unchanged.
+func lookupOwnership(owner string, group string) (int, int, error) {
+	uid, gid := -1, -1
+
+	if owner != "" {
+		id := owner
+		if _, err := strconv.Atoi(owner); err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return -1, -1, err
+			}
+			id = u.Uid
+		}
+		uid, _ = strconv.Atoi(id)
+	}
+
+	if group != "" {
+		id := group
+		if _, err := strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return -1, -1, err
+			}
+			id = g.Gid
+		}
+		gid, _ = strconv.Atoi(id)
+	}
+
+	return uid, gid, nil
+}
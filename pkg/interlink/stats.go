@@ -0,0 +1,52 @@
+package interlink
+
+import "time"
+
+// PodStats holds the resource usage of a Pod, as measured by the sidecar at Time. It is returned by the /stats route of the sidecars.
+// Every field is optional: usages the sidecar is not able to measure are left nil.
+type PodStats struct {
+	PodName      string           `json:"name"`
+	PodUID       string           `json:"UID"`
+	PodNamespace string           `json:"namespace"`
+	Time         time.Time        `json:"time"`
+	Containers   []ContainerStats `json:"containers"`
+}
+
+// ContainerStats holds the resource usage of a container
+type ContainerStats struct {
+	Name    string        `json:"name"`
+	CPU     *CPUStats     `json:"cpu,omitempty"`
+	Memory  *MemoryStats  `json:"memory,omitempty"`
+	Network *NetworkStats `json:"network,omitempty"`
+	// Filesystem is the usage of the writable layer of the container, or of its working directory on the remote resource
+	Filesystem *FsStats `json:"filesystem,omitempty"`
+}
+
+// CPUStats holds the CPU usage of a container. UsageCoreNanoSeconds is cumulative and is the one rates are computed from, e.g. by metrics-server.
+type CPUStats struct {
+	UsageNanoCores       *uint64 `json:"usageNanoCores,omitempty"`
+	UsageCoreNanoSeconds *uint64 `json:"usageCoreNanoSeconds,omitempty"`
+}
+
+// MemoryStats holds the memory usage of a container. WorkingSetBytes is the one reported by kubectl top.
+type MemoryStats struct {
+	UsageBytes      *uint64 `json:"usageBytes,omitempty"`
+	WorkingSetBytes *uint64 `json:"workingSetBytes,omitempty"`
+	RSSBytes        *uint64 `json:"rssBytes,omitempty"`
+}
+
+// NetworkStats holds the cumulative network traffic of a container. Containers sharing the network of the Pod should report it only once.
+type NetworkStats struct {
+	RxBytes  *uint64 `json:"rxBytes,omitempty"`
+	RxErrors *uint64 `json:"rxErrors,omitempty"`
+	TxBytes  *uint64 `json:"txBytes,omitempty"`
+	TxErrors *uint64 `json:"txErrors,omitempty"`
+}
+
+// FsStats holds the usage of a filesystem
+type FsStats struct {
+	AvailableBytes *uint64 `json:"availableBytes,omitempty"`
+	CapacityBytes  *uint64 `json:"capacityBytes,omitempty"`
+	UsedBytes      *uint64 `json:"usedBytes,omitempty"`
+	InodesUsed     *uint64 `json:"inodesUsed,omitempty"`
+}
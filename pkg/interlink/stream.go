@@ -0,0 +1,31 @@
+package interlink
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// StreamResponse copies src to w, flushing after every read so that streamed content (e.g. followed logs)
+// reaches the client as soon as it is produced. It returns when src is exhausted or a write fails.
+func StreamResponse(w http.ResponseWriter, src io.Reader) error {
+	flusher, canFlush := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			_, writeErr := w.Write(buf[:n])
+			if writeErr != nil {
+				return writeErr
+			}
+			if canFlush {
+				flusher.Flush()
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
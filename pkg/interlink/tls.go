@@ -0,0 +1,101 @@
+package interlink
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSConfig holds the certificates used to secure a connection.
+// Server side, CertFile and KeyFile are the server identity and CACertFile, if set, is used to verify client certificates.
+// Client side, CertFile and KeyFile are the client identity presented for mutual TLS and CACertFile is used to verify the server certificate.
+// ServerName is the name the server certificate is verified against, by default the host of the URL, or UnixSocketServerName over a Unix socket.
+type TLSConfig struct {
+	CertFile           string `yaml:"CertFile"`
+	KeyFile            string `yaml:"KeyFile"`
+	CACertFile         string `yaml:"CACertFile"`
+	ServerName         string `yaml:"ServerName"`
+	InsecureSkipVerify bool   `yaml:"InsecureSkipVerify"`
+}
+
+// Enabled returns true if any TLS option has been set. It tells whether a client talks TLS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || c.CACertFile != "" || c.ServerName != "" || c.InsecureSkipVerify
+}
+
+// ServerEnabled returns true if a server identity has been set, CertFile or KeyFile. It tells whether a server serves TLS:
+// CACertFile and InsecureSkipVerify alone have no meaning server side.
+func (c TLSConfig) ServerEnabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// ServerTLSConfig returns a tls.Config serving CertFile/KeyFile.
+// If CACertFile is set, clients are required to present a certificate signed by that CA.
+func (c TLSConfig) ServerTLSConfig() (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("both CertFile and KeyFile are needed to serve TLS")
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.CACertFile != "" {
+		pool, err := loadCertPool(c.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+// ClientTLSConfig returns a tls.Config verifying the server against CACertFile (or the system pool if empty)
+// and presenting CertFile/KeyFile as client certificate, if set.
+func (c TLSConfig) ClientTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec
+	}
+
+	if c.CACertFile != "" {
+		pool, err := loadCertPool(c.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// loadCertPool reads a PEM bundle of CA certificates
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid certificate found in %s", path)
+	}
+	return pool, nil
+}
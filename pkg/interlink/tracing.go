@@ -0,0 +1,64 @@
+package interlink
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+// DefaultTracingServiceName is the service name InterLink spans are reported with when ServiceName is not set
+const DefaultTracingServiceName = "InterLink-API"
+
+// InitTracing registers a global TracerProvider exporting spans to the configured OTLP collector,
+// and the W3C trace context propagator, so that traceparent headers are read from incoming requests and sent to the sidecars.
+// The returned function flushes the pending spans and shuts the exporter down.
+func InitTracing(ctx context.Context, config TracingConfig) (func(context.Context) error, error) {
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultTracingServiceName
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(Version),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(config.Endpoint)}
+	if config.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(config.Headers))
+	}
+	traceExporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if config.SampleRatio != nil {
+		sampler = sdktrace.TraceIDRatioBased(*config.SampleRatio)
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		// spans belonging to a sampled VK trace are always recorded
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(res),
+		sdktrace.WithBatcher(traceExporter),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return tracerProvider.Shutdown, nil
+}
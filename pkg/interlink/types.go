@@ -1,6 +1,7 @@
 package interlink
 
 import (
+	"encoding/json"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
@@ -11,6 +12,52 @@ type PodCreateRequests struct {
 	Pod        v1.Pod         `json:"pod"`
 	ConfigMaps []v1.ConfigMap `json:"configmaps"`
 	Secrets    []v1.Secret    `json:"secrets"`
+	// ProjectedVolumes are the projected and downwardAPI volumes of the Pod, rendered by the VK
+	ProjectedVolumes []ProjectedVolume `json:"projectedVolumes,omitempty"`
+	// Env is the environment of every container, by container name, resolved by the VK from envFrom, valueFrom and $(VAR) references
+	Env map[string][]v1.EnvVar `json:"env,omitempty"`
+	// MappedVolumes are the PersistentVolumeClaim and hostPath volumes of the Pod, translated by the VK to the storage of the remote site
+	MappedVolumes []MappedVolume `json:"mappedVolumes,omitempty"`
+}
+
+// MappedVolume is a PersistentVolumeClaim or hostPath volume translated to the storage of the remote site, either to RemotePath
+// or to a volume of the plugin described by Driver and Options. Name is the name of the volume in the Pod spec.
+// A hostPath volume no mapping matches has only Name and HostPath set: the plugin decides how to mount it, or rejects the Pod.
+type MappedVolume struct {
+	Name       string            `json:"name"`
+	ClaimName  string            `json:"claimName,omitempty"`
+	HostPath   string            `json:"hostPath,omitempty"`
+	RemotePath string            `json:"remotePath,omitempty"`
+	Driver     string            `json:"driver,omitempty"`
+	Options    map[string]string `json:"options,omitempty"`
+	ReadOnly   bool              `json:"readOnly,omitempty"`
+}
+
+// ProjectedVolume holds the files of a projected or downwardAPI volume, rendered by the VK from its ConfigMaps, Secrets,
+// service account tokens and Pod fields. Name is the name of the volume in the Pod spec.
+// Service account tokens are not refreshed: they expire after the expiration requested in the Pod spec.
+type ProjectedVolume struct {
+	Name  string       `json:"name"`
+	Files []VolumeFile `json:"files"`
+}
+
+// VolumeFile is a file of a ProjectedVolume or a MountedVolume. Path is relative to the mount point of the volume, Mode is already
+// resolved from the item and the volume default modes.
+type VolumeFile struct {
+	Path    string `json:"path"`
+	Content []byte `json:"content"`
+	Mode    int32  `json:"mode"`
+}
+
+// MountedVolume is a ConfigMap, Secret, projected or downwardAPI volume mounted by a container, normalized by InterLink
+// into the files the kubelet would write: items select and rename the keys, modes are resolved from the items and the
+// default mode, and a missing optional source is an empty volume. Name is the name of the volume in the Pod spec.
+type MountedVolume struct {
+	Name      string       `json:"name"`
+	MountPath string       `json:"mountPath"`
+	SubPath   string       `json:"subPath,omitempty"`
+	ReadOnly  bool         `json:"readOnly,omitempty"`
+	Files     []VolumeFile `json:"files"`
 }
 
 // PodStatus is a simplified v1.Pod struct, holding only necessary variables to uniquely identify a job/service in the sidecar. It is used to request
@@ -19,6 +66,9 @@ type PodStatus struct {
 	PodUID       string               `json:"UID"`
 	PodNamespace string               `json:"namespace"`
 	Containers   []v1.ContainerStatus `json:"containers"`
+	// NotFound is set by the sidecar when the Pod is not on the remote resource, e.g. because its creation never reached it.
+	// Containers is then empty.
+	NotFound bool `json:"notFound,omitempty"`
 }
 
 // RetrievedContainer is used in InterLink to rearrange data structure in a suitable way for the sidecar
@@ -27,6 +77,15 @@ type RetrievedContainer struct {
 	ConfigMaps []v1.ConfigMap `json:"configMaps"`
 	Secrets    []v1.Secret    `json:"secrets"`
 	EmptyDirs  []string       `json:"emptyDirs"`
+	// ProjectedVolumes are the projected and downwardAPI volumes mounted by the container
+	ProjectedVolumes []ProjectedVolume `json:"projectedVolumes,omitempty"`
+	// Env is the resolved environment of the container: every variable has a plain Value
+	Env []v1.EnvVar `json:"env,omitempty"`
+	// MappedVolumes are the PersistentVolumeClaim and hostPath volumes mounted by the container, mapped to the remote storage
+	MappedVolumes []MappedVolume `json:"mappedVolumes,omitempty"`
+	// MountedVolumes are the files of the ConfigMap, Secret, projected and downwardAPI volumes, per mount of the container.
+	// As they hold the content of the ConfigMaps and Secrets, they are only sent with the rest of the container data, when ExportPodData is set.
+	MountedVolumes []MountedVolume `json:"mountedVolumes,omitempty"`
 }
 
 // RetrievedPoData is used in InterLink to rearrange data structure in a suitable way for the sidecar
@@ -54,3 +113,98 @@ type LogStruct struct {
 	ContainerName string           `json:"ContainerName"`
 	Opts          ContainerLogOpts `json:"Opts"`
 }
+
+// Error is the JSON body InterLink answers with when a call fails. SidecarMessage holds the original response of the sidecar, if the failure comes from it
+type Error struct {
+	Code           int    `json:"code"`
+	Message        string `json:"message"`
+	Sidecar        string `json:"sidecar,omitempty"`
+	SidecarMessage string `json:"sidecarMessage,omitempty"`
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if e.SidecarMessage != "" {
+		msg += ": " + e.SidecarMessage
+	}
+	return msg
+}
+
+// PodCreateResult reports the outcome of the creation of a single Pod in a batch. Error is nil when the Pod has been created
+type PodCreateResult struct {
+	PodName      string `json:"name"`
+	PodUID       string `json:"UID"`
+	PodNamespace string `json:"namespace"`
+	Error        *Error `json:"error,omitempty"`
+}
+
+// OperationType tells whether an Operation creates or deletes a Pod
+type OperationType string
+
+const (
+	OperationCreate OperationType = "create"
+	OperationDelete OperationType = "delete"
+)
+
+// OperationState is the state of an Operation
+type OperationState string
+
+const (
+	OperationPending   OperationState = "Pending"
+	OperationRunning   OperationState = "Running"
+	OperationSucceeded OperationState = "Succeeded"
+	OperationFailed    OperationState = "Failed"
+)
+
+// Operation tracks a Pod creation or deletion submitted asynchronously to InterLink.
+// Result holds the sidecar response once succeeded, Error the reason of the failure once failed.
+// OutcomeUnknown is set on the failed operations that may have been applied by the sidecar anyway, e.g. after a timeout,
+// an unreachable sidecar or an InterLink restart, but not on those refused by the open circuit breaker of the sidecar, never sent.
+// A new creation of the Pod is refused while its latest creation has an unknown outcome, until the sidecar reports the Pod,
+// or that it is not found, or the Pod is deleted, which clears it.
+type Operation struct {
+	ID             string          `json:"id"`
+	Type           OperationType   `json:"type"`
+	PodName        string          `json:"name"`
+	PodUID         string          `json:"UID"`
+	PodNamespace   string          `json:"namespace"`
+	IdempotencyKey string          `json:"idempotencyKey,omitempty"`
+	State          OperationState  `json:"state"`
+	Result         json.RawMessage `json:"result,omitempty"`
+	Error          *Error          `json:"error,omitempty"`
+	OutcomeUnknown bool            `json:"outcomeUnknown,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+}
+
+// CreateOperationRequest is a Pod creation submitted with others in a single batch of operations, along with its own idempotency key
+type CreateOperationRequest struct {
+	PodCreateRequests
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+}
+
+// Finished returns true once the Operation either succeeded or failed
+func (o *Operation) Finished() bool {
+	return o.State == OperationSucceeded || o.State == OperationFailed
+}
+
+// NodeResources holds the resources of the remote site, returned by the /resources route of the sidecars.
+// Capacity is the total amount of every resource, Allocatable the amount the Pods of the virtual node may use, e.g. Capacity minus what other
+// users of the remote site currently hold. Kubernetes subtracts the requests of the Pods already scheduled on the node by itself.
+// Allocatable defaults to Capacity for every resource it does not list.
+type NodeResources struct {
+	Capacity    v1.ResourceList `json:"capacity"`
+	Allocatable v1.ResourceList `json:"allocatable,omitempty"`
+}
+
+// AllocatableOrCapacity returns Allocatable, completed with the Capacity of the resources it does not list
+func (r NodeResources) AllocatableOrCapacity() v1.ResourceList {
+	allocatable := v1.ResourceList{}
+	for name, quantity := range r.Capacity {
+		allocatable[name] = quantity
+	}
+	for name, quantity := range r.Allocatable {
+		allocatable[name] = quantity
+	}
+	return allocatable
+}
@@ -0,0 +1,4 @@
+package interlink
+
+// Version is the InterLink release. It is overridden at build time with -ldflags "-X github.com/intertwin-eu/interlink/pkg/interlink.Version=..."
+var Version = "development"
@@ -0,0 +1,82 @@
+package interlink
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// DefaultVolumeMode is the mode of the files of ConfigMap, Secret, projected and downwardAPI volumes setting no default mode
+const DefaultVolumeMode int32 = 0644
+
+// VolumeMode returns the default mode set in a volume source, or DefaultVolumeMode
+func VolumeMode(mode *int32) int32 {
+	if mode == nil {
+		return DefaultVolumeMode
+	}
+	return *mode
+}
+
+// ItemMode returns the mode set in an item of a volume source, or the default mode of the volume
+func ItemMode(mode *int32, defaultMode int32) int32 {
+	if mode == nil {
+		return defaultMode
+	}
+	return *mode
+}
+
+// ConfigMapData returns the data and the binary data of a ConfigMap as a single map
+func ConfigMapData(cfgmap v1.ConfigMap) map[string][]byte {
+	data := make(map[string][]byte, len(cfgmap.Data)+len(cfgmap.BinaryData))
+	for key, value := range cfgmap.Data {
+		data[key] = []byte(value)
+	}
+	for key, value := range cfgmap.BinaryData {
+		data[key] = value
+	}
+	return data
+}
+
+// SecretData returns the data of a Secret, with the string data not yet merged by the API server
+func SecretData(secret v1.Secret) map[string][]byte {
+	data := make(map[string][]byte, len(secret.Data)+len(secret.StringData))
+	for key, value := range secret.StringData {
+		data[key] = []byte(value)
+	}
+	for key, value := range secret.Data {
+		data[key] = value
+	}
+	return data
+}
+
+// KeysToFiles returns the files the kubelet writes for the keys of a ConfigMap or Secret: a file per key, sorted by name,
+// or a file per item if any, named after the item path. A key missing from data is an error, unless optional.
+// source names the ConfigMap or Secret in the error.
+func KeysToFiles(source string, data map[string][]byte, items []v1.KeyToPath, optional bool, defaultMode int32) ([]VolumeFile, error) {
+	var files []VolumeFile
+	if len(items) == 0 {
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			files = append(files, VolumeFile{Path: key, Content: data[key], Mode: defaultMode})
+		}
+		return files, nil
+	}
+
+	for _, item := range items {
+		value, ok := data[item.Key]
+		if !ok {
+			if optional {
+				continue
+			}
+			return nil, fmt.Errorf("key %s not found in %s", item.Key, source)
+		}
+		files = append(files, VolumeFile{Path: path.Clean(item.Path), Content: value, Mode: ItemMode(item.Mode, defaultMode)})
+	}
+	return files, nil
+}
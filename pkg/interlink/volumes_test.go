@@ -0,0 +1,83 @@
+package interlink
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestKeysToFiles(t *testing.T) {
+	data := map[string][]byte{"b": []byte("2"), "a": []byte("1")}
+
+	tests := []struct {
+		name     string
+		items    []v1.KeyToPath
+		optional bool
+		want     []VolumeFile
+		wantErr  bool
+	}{
+		{
+			name: "every key, sorted, with the default mode",
+			want: []VolumeFile{{Path: "a", Content: []byte("1"), Mode: 0600}, {Path: "b", Content: []byte("2"), Mode: 0600}},
+		},
+		{
+			name:  "items select and rename the keys",
+			items: []v1.KeyToPath{{Key: "b", Path: "dir/../renamed"}},
+			want:  []VolumeFile{{Path: "renamed", Content: []byte("2"), Mode: 0600}},
+		},
+		{
+			name:  "item mode overrides the default mode",
+			items: []v1.KeyToPath{{Key: "a", Path: "a", Mode: int32Ptr(0400)}, {Key: "b", Path: "b"}},
+			want:  []VolumeFile{{Path: "a", Content: []byte("1"), Mode: 0400}, {Path: "b", Content: []byte("2"), Mode: 0600}},
+		},
+		{
+			name:    "missing key",
+			items:   []v1.KeyToPath{{Key: "c", Path: "c"}},
+			wantErr: true,
+		},
+		{
+			name:     "missing key of an optional source",
+			items:    []v1.KeyToPath{{Key: "c", Path: "c"}, {Key: "a", Path: "a"}},
+			optional: true,
+			want:     []VolumeFile{{Path: "a", Content: []byte("1"), Mode: 0600}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := KeysToFiles("ConfigMap test", data, tt.items, tt.optional, 0600)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(files, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, files)
+			}
+		})
+	}
+}
+
+func TestVolumeModes(t *testing.T) {
+	if mode := VolumeMode(nil); mode != DefaultVolumeMode {
+		t.Errorf("expected the default volume mode %o, got %o", DefaultVolumeMode, mode)
+	}
+	if mode := VolumeMode(int32Ptr(0400)); mode != 0400 {
+		t.Errorf("expected 400, got %o", mode)
+	}
+	if mode := ItemMode(nil, 0440); mode != 0440 {
+		t.Errorf("expected the volume default mode 440, got %o", mode)
+	}
+	if mode := ItemMode(int32Ptr(0600), 0440); mode != 0600 {
+		t.Errorf("expected the item mode 600, got %o", mode)
+	}
+}
@@ -0,0 +1,134 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/containerd/containerd/log"
+	"github.com/gorilla/websocket"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// streamUpgrader upgrades the exec and attach calls of InterLink, which is not a browser: the Origin header is not checked
+var streamUpgrader = websocket.Upgrader{
+	Subprotocols: []string{commonIL.StreamProtocol},
+	CheckOrigin:  func(r *http.Request) bool { return true },
+}
+
+// ExecHandler serves the /exec websocket stream by calling Execer.Exec, if implemented by the Provider
+func (h *SidecarHandler) ExecHandler(w http.ResponseWriter, r *http.Request) {
+	h.serveStream(w, r, "exec", Execer.Exec)
+}
+
+// AttachHandler serves the /attach websocket stream by calling Execer.Attach, if implemented by the Provider
+func (h *SidecarHandler) AttachHandler(w http.ResponseWriter, r *http.Request) {
+	h.serveStream(w, r, "attach", Execer.Attach)
+}
+
+// serveStream upgrades the call to a websocket, demultiplexes stdin and resize events for the Provider and multiplexes its stdout and stderr.
+// Once the Provider returns, the outcome is sent as an ExecResult on the status channel and the stream is closed.
+func (h *SidecarHandler) serveStream(w http.ResponseWriter, r *http.Request, name string, run func(Execer, context.Context, commonIL.ExecRequest, Streams) error) {
+	log.G(h.Ctx).Info("Sidecar: received " + name + " call")
+
+	execer, ok := h.Provider.(Execer)
+	if !ok {
+		http.Error(w, "the plugin does not support "+name, http.StatusNotImplemented)
+		return
+	}
+	req, err := commonIL.ParseExecRequest(r.URL.Query())
+	if err != nil {
+		log.G(h.Ctx).Error(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	wsConn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already answered InterLink
+		log.G(h.Ctx).Error(err)
+		return
+	}
+	conn := commonIL.NewStreamConn(wsConn)
+	defer conn.Close()
+
+	// the session is over when the user goes away, i.e. when the stream is closed from the other side
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	streams := Streams{}
+	var stdinReader *io.PipeReader
+	var stdinWriter *io.PipeWriter
+	if req.Stdin {
+		stdinReader, stdinWriter = io.Pipe()
+		streams.Stdin = stdinReader
+		// unblocks the stream reader if the Provider returns without reading the whole stdin
+		defer stdinReader.Close()
+	}
+	if req.Stdout {
+		streams.Stdout = conn.Writer(commonIL.StreamStdout)
+	}
+	if req.Stderr {
+		streams.Stderr = conn.Writer(commonIL.StreamStderr)
+	}
+	var resize chan commonIL.TermSize
+	if req.TTY {
+		resize = make(chan commonIL.TermSize, 1)
+		streams.Resize = resize
+	}
+
+	go func() {
+		defer cancel()
+		if stdinWriter != nil {
+			defer stdinWriter.Close()
+		}
+		if resize != nil {
+			defer close(resize)
+		}
+
+		for {
+			channel, payload, err := conn.ReadFrame()
+			if err != nil {
+				return
+			}
+			switch channel {
+			case commonIL.StreamStdin:
+				if stdinWriter != nil {
+					stdinWriter.Write(payload)
+				}
+			case commonIL.StreamResize:
+				var size commonIL.TermSize
+				if resize != nil && json.Unmarshal(payload, &size) == nil {
+					// only the latest size matters, if the Provider has not read the previous one yet
+					select {
+					case <-resize:
+					default:
+					}
+					resize <- size
+				}
+			case commonIL.StreamClose:
+				if len(payload) == 1 && payload[0] == commonIL.StreamStdin && stdinWriter != nil {
+					stdinWriter.Close()
+				}
+			}
+		}
+	}()
+
+	err = run(execer, ctx, req, streams)
+
+	var result commonIL.ExecResult
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		result.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		log.G(h.Ctx).Error(err)
+		result.Error = err.Error()
+	}
+	err = conn.WriteJSON(commonIL.StreamStatus, result)
+	if err != nil && ctx.Err() == nil {
+		log.G(h.Ctx).Error(err)
+	}
+}
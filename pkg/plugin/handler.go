@@ -0,0 +1,261 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/containerd/containerd/log"
+	v1 "k8s.io/api/core/v1"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// SidecarHandler decodes the requests InterLink forwards to the sidecar, calls the Provider and encodes its responses
+type SidecarHandler struct {
+	Provider Provider
+	Ctx      context.Context
+}
+
+// NewSidecarHandler returns a SidecarHandler serving the given Provider
+func NewSidecarHandler(ctx context.Context, provider Provider) *SidecarHandler {
+	return &SidecarHandler{
+		Provider: provider,
+		Ctx:      ctx,
+	}
+}
+
+// Mux returns a http.ServeMux with every sidecar route registered
+func (h *SidecarHandler) Mux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/create", h.CreateHandler)
+	mux.HandleFunc("/delete", h.DeleteHandler)
+	mux.HandleFunc("/status", h.StatusHandler)
+	mux.HandleFunc("/getLogs", h.GetLogsHandler)
+	mux.HandleFunc("/stats", h.StatsHandler)
+	mux.HandleFunc("/resources", h.ResourcesHandler)
+	mux.HandleFunc("/exec", h.ExecHandler)
+	mux.HandleFunc("/attach", h.AttachHandler)
+	mux.HandleFunc("/portforward", h.PortForwardHandler)
+	return mux
+}
+
+// ListenAndServe serves the Provider on the given address.
+// A unix:// address binds a Unix domain socket, readable and writable by owner and group only; anything else is a TCP address.
+func ListenAndServe(ctx context.Context, addr string, provider Provider) error {
+	mux := NewSidecarHandler(ctx, provider).Mux()
+	if !commonIL.IsUnixSocket(addr) {
+		return http.ListenAndServe(addr, mux)
+	}
+
+	socketPath := commonIL.UnixSocketPath(addr)
+	listener, err := commonIL.ListenUnixSocket(socketPath, commonIL.DefaultSocketMode, "", "")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socketPath)
+	return http.Serve(listener, mux)
+}
+
+// decodeBody unmarshals the request body in out. On failure it answers with a 400 status code and returns false.
+func (h *SidecarHandler) decodeBody(w http.ResponseWriter, r *http.Request, out interface{}) bool {
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(bodyBytes, out)
+	}
+	if err != nil {
+		log.G(h.Ctx).Error(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// CreateHandler calls Provider.Create for every Pod forwarded by InterLink
+func (h *SidecarHandler) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	log.G(h.Ctx).Info("Sidecar: received Create call")
+
+	var pods []commonIL.RetrievedPodData
+	if !h.decodeBody(w, r, &pods) {
+		return
+	}
+
+	// every Pod is created even if a previous one failed, and its outcome is reported in the results
+	statusCode := http.StatusOK
+	results := make([]commonIL.PodCreateResult, 0, len(pods))
+	for _, pod := range pods {
+		result := commonIL.PodCreateResult{PodName: pod.Pod.Name, PodUID: string(pod.Pod.UID), PodNamespace: pod.Pod.Namespace}
+		err := h.Provider.Create(r.Context(), pod)
+		if err != nil {
+			log.G(h.Ctx).Error(err)
+			statusCode = http.StatusInternalServerError
+			result.Error = &commonIL.Error{Code: http.StatusInternalServerError, Message: err.Error()}
+		}
+		results = append(results, result)
+	}
+
+	returnValue, err := json.Marshal(results)
+	if err != nil {
+		log.G(h.Ctx).Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(returnValue)
+}
+
+// DeleteHandler calls Provider.Delete for the Pod forwarded by InterLink
+func (h *SidecarHandler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
+	log.G(h.Ctx).Info("Sidecar: received Delete call")
+
+	var pod *v1.Pod
+	if !h.decodeBody(w, r, &pod) {
+		return
+	}
+
+	err := h.Provider.Delete(r.Context(), pod)
+	if err != nil {
+		log.G(h.Ctx).Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Containers deleted"))
+}
+
+// StatusHandler calls Provider.Status for every Pod forwarded by InterLink and returns the list of statuses.
+// The Pods the Provider reports with ErrPodNotFound are returned with NotFound set.
+func (h *SidecarHandler) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	log.G(h.Ctx).Info("Sidecar: received GetStatus call")
+
+	var pods []*v1.Pod
+	if !h.decodeBody(w, r, &pods) {
+		return
+	}
+
+	returnedStatuses := []commonIL.PodStatus{}
+	for _, pod := range pods {
+		status, err := h.Provider.Status(r.Context(), pod)
+		if errors.Is(err, ErrPodNotFound) {
+			returnedStatuses = append(returnedStatuses, commonIL.PodStatus{PodName: pod.Name, PodUID: string(pod.UID), PodNamespace: pod.Namespace, NotFound: true})
+			continue
+		} else if err != nil {
+			log.G(h.Ctx).Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		returnedStatuses = append(returnedStatuses, status)
+	}
+
+	returnValue, err := json.Marshal(returnedStatuses)
+	if err != nil {
+		log.G(h.Ctx).Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(returnValue)
+}
+
+// StatsHandler calls StatsReporter.Stats for every Pod forwarded by InterLink, if implemented by the Provider, and returns the list of stats.
+// The Pods whose stats cannot be measured are left out, so that InterLink reports them as unavailable.
+func (h *SidecarHandler) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	log.G(h.Ctx).Info("Sidecar: received GetStats call")
+
+	reporter, ok := h.Provider.(StatsReporter)
+	if !ok {
+		http.Error(w, "the plugin does not support stats", http.StatusNotImplemented)
+		return
+	}
+	var pods []*v1.Pod
+	if !h.decodeBody(w, r, &pods) {
+		return
+	}
+
+	returnedStats := []commonIL.PodStats{}
+	for _, pod := range pods {
+		stats, err := reporter.Stats(r.Context(), pod)
+		if err != nil {
+			log.G(h.Ctx).Error(err)
+			continue
+		}
+		if stats.Time.IsZero() {
+			stats.Time = time.Now()
+		}
+		returnedStats = append(returnedStats, stats)
+	}
+
+	returnValue, err := json.Marshal(returnedStats)
+	if err != nil {
+		log.G(h.Ctx).Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(returnValue)
+}
+
+// ResourcesHandler calls ResourceReporter.Resources, if implemented by the Provider, and returns the resources of the remote site
+func (h *SidecarHandler) ResourcesHandler(w http.ResponseWriter, r *http.Request) {
+	log.G(h.Ctx).Info("Sidecar: received GetResources call")
+
+	reporter, ok := h.Provider.(ResourceReporter)
+	if !ok {
+		http.Error(w, "the plugin does not support resources", http.StatusNotImplemented)
+		return
+	}
+
+	resources, err := reporter.Resources(r.Context())
+	if err != nil {
+		log.G(h.Ctx).Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	returnValue, err := json.Marshal(resources)
+	if err != nil {
+		log.G(h.Ctx).Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(returnValue)
+}
+
+// GetLogsHandler calls Provider.Logs and streams the returned logs in the response, flushing as they are produced.
+// The request context is cancelled when InterLink closes the connection, so followed logs should stop on ctx.Done().
+func (h *SidecarHandler) GetLogsHandler(w http.ResponseWriter, r *http.Request) {
+	log.G(h.Ctx).Info("Sidecar: received GetLogs call")
+
+	var req commonIL.LogStruct
+	if !h.decodeBody(w, r, &req) {
+		return
+	}
+
+	logs, err := h.Provider.Logs(r.Context(), req)
+	if err != nil {
+		log.G(h.Ctx).Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer logs.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	err = commonIL.StreamResponse(w, logs)
+	if err != nil && r.Context().Err() == nil {
+		log.G(h.Ctx).Error(err)
+	}
+}
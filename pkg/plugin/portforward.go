@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/containerd/containerd/log"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// PortForwardHandler serves the /portforward websocket stream by calling PortForwarder.PortForward, if implemented by the Provider.
+// If the forwarding fails, the error is sent to InterLink before closing the stream.
+func (h *SidecarHandler) PortForwardHandler(w http.ResponseWriter, r *http.Request) {
+	log.G(h.Ctx).Info("Sidecar: received PortForward call")
+
+	forwarder, ok := h.Provider.(PortForwarder)
+	if !ok {
+		http.Error(w, "the plugin does not support portforward", http.StatusNotImplemented)
+		return
+	}
+	req, err := commonIL.ParsePortForwardRequest(r.URL.Query())
+	if err != nil {
+		log.G(h.Ctx).Error(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	wsConn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already answered InterLink
+		log.G(h.Ctx).Error(err)
+		return
+	}
+	conn := commonIL.NewStreamConn(wsConn)
+	defer conn.Close()
+
+	err = forwarder.PortForward(r.Context(), req, commonIL.NewPortForwardStream(conn))
+	if err != nil {
+		log.G(h.Ctx).Error(err)
+		conn.WriteFrame(commonIL.PortForwardError, []byte(err.Error()))
+	}
+}
+
+// ForwardTo forwards stream to a TCP address, e.g. the IP of the Pod and the requested port.
+// A half-close of InterLink is propagated to the connection, so that protocols waiting for the end of the request keep working,
+// while the stream is closed as soon as the connection is over: the context of the hijacked call is not cancelled when the VK goes away,
+// so waiting for the VK to close its side too could leave the stream open forever.
+func ForwardTo(ctx context.Context, address string, stream io.ReadWriteCloser) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	go func() {
+		_, err := io.Copy(conn, stream)
+		if tcpConn, ok := conn.(*net.TCPConn); ok && err == nil {
+			tcpConn.CloseWrite()
+		} else {
+			conn.Close()
+		}
+	}()
+
+	_, err = io.Copy(stream, conn)
+	stream.Close()
+	return err
+}
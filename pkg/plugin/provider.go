@@ -0,0 +1,74 @@
+// Package plugin is a SDK to write InterLink sidecar plugins in Go.
+// A plugin implements the Provider interface, while SidecarHandler takes care of the HTTP wire contract expected by InterLink.
+package plugin
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	v1 "k8s.io/api/core/v1"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// ErrPodNotFound is returned by Provider.Status for the Pods missing from the remote resource. They are reported to InterLink
+// as not found, so that a creation whose outcome was unknown can be sent again.
+var ErrPodNotFound = errors.New("Pod not found on the remote resource")
+
+// Provider is the interface a sidecar plugin has to implement to run Pods on a remote resource
+type Provider interface {
+	// Create submits the Pod to the remote resource. ConfigMaps, Secrets and EmptyDirs needed by each container
+	// have already been retrieved by InterLink.
+	Create(ctx context.Context, pod commonIL.RetrievedPodData) error
+	// Delete removes the Pod from the remote resource
+	Delete(ctx context.Context, pod *v1.Pod) error
+	// Status returns the status of the Pod and its containers on the remote resource.
+	// A Pod missing from the remote resource is reported by returning ErrPodNotFound.
+	Status(ctx context.Context, pod *v1.Pod) (commonIL.PodStatus, error)
+	// Logs returns the logs of a container, according to the provided options. The returned stream is closed by the caller.
+	Logs(ctx context.Context, req commonIL.LogStruct) (io.ReadCloser, error)
+}
+
+// Execer is implemented by the Providers able to run commands in containers and to attach to them, serving kubectl exec and attach.
+// It is optional: the exec and attach calls of the Providers not implementing it are answered with 501 Not Implemented.
+type Execer interface {
+	// Exec runs req.Command in the container and returns once it is over. An error with an ExitCode() int method,
+	// such as *exec.ExitError, reports the exit code of the command to the user.
+	Exec(ctx context.Context, req commonIL.ExecRequest, streams Streams) error
+	// Attach attaches streams to the main process of the container, until it exits or ctx is done
+	Attach(ctx context.Context, req commonIL.ExecRequest, streams Streams) error
+}
+
+// Streams are the standard streams of an exec or attach session. Only the streams asked for by the request are set,
+// Resize is set for TTY sessions only and receives the terminal size whenever the user resizes it.
+type Streams struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	Resize <-chan commonIL.TermSize
+}
+
+// PortForwarder is implemented by the Providers able to forward connections to the ports of their Pods, serving kubectl port-forward.
+// It is optional: the port forward calls of the Providers not implementing it are answered with 501 Not Implemented.
+type PortForwarder interface {
+	// PortForward forwards stream to req.Port of the Pod and returns once the forwarded connection is over.
+	// Concurrent connections to the same Pod get concurrent calls, each with its own stream.
+	// ForwardTo can be used when the Pod is reachable over the network from the plugin.
+	PortForward(ctx context.Context, req commonIL.PortForwardRequest, stream io.ReadWriteCloser) error
+}
+
+// StatsReporter is implemented by the Providers able to measure the resource usage of their Pods, serving kubectl top and metrics-server.
+// It is optional: the Pods of the Providers not implementing it are reported as having no stats available.
+type StatsReporter interface {
+	// Stats returns the current resource usage of the Pod and its containers. Time defaults to the time of the call.
+	Stats(ctx context.Context, pod *v1.Pod) (commonIL.PodStats, error)
+}
+
+// ResourceReporter is implemented by the Providers able to tell the resources of their remote site, e.g. the free cores of a batch system.
+// It is optional: the virtual nodes of the Providers not implementing it advertise the resources set in their configuration.
+type ResourceReporter interface {
+	// Resources returns the current capacity of the remote site and the resources its Pods may use, see commonIL.NodeResources.
+	// The resources not reported keep their configured value on the virtual node.
+	Resources(ctx context.Context) (commonIL.NodeResources, error)
+}
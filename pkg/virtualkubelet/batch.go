@@ -0,0 +1,177 @@
+package virtualkubelet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/log"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+	"github.com/intertwin-eu/interlink/pkg/interlink/client"
+)
+
+// operationPollInterval is how often the VK asks InterLink whether an operation is over
+const operationPollInterval = time.Second
+
+// pendingCreate is a Pod creation waiting for its batch to be submitted
+type pendingCreate struct {
+	ctx    context.Context
+	req    commonIL.CreateOperationRequest
+	result chan submitResult
+}
+
+// submitResult is the operation InterLink started for a pendingCreate, or the error of the submit
+type submitResult struct {
+	op  *commonIL.Operation
+	err error
+}
+
+// createBatcher submits the Pod creations arriving within window to InterLink as a single batch of operations,
+// so that a burst of Pods doesn't turn into a burst of sidecar calls
+type createBatcher struct {
+	client  *client.Client
+	window  time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	pending []pendingCreate
+	timer   *time.Timer
+	// unsupported is set once InterLink turns out not to serve batches of operations, i.e. it is an older release
+	unsupported bool
+}
+
+func newCreateBatcher(interLinkClient *client.Client, window time.Duration, maxSize int) *createBatcher {
+	return &createBatcher{client: interLinkClient, window: window, maxSize: maxSize}
+}
+
+// createIdempotencyKey is the idempotency key of the creation of the Pod: every submit for the same Pod, e.g. retried after a network error
+// or repeated after a VK restart, gets the operation already there instead of creating a second remote job.
+// A creation sent again once the sidecar reported the Pod as not found has a key of its own per attempt, so that it is run anew.
+func createIdempotencyKey(pod commonIL.PodCreateRequests, attempt int) string {
+	if attempt > 0 {
+		return "create-" + string(pod.Pod.UID) + "-" + strconv.Itoa(attempt)
+	}
+	return "create-" + string(pod.Pod.UID)
+}
+
+// create asks InterLink to create the Pod with an operation, submitted with the other creations arriving within the batch window,
+// and waits for the operation to be over. It returns the error of this Pod only, as the other Pods of the batch may have been created.
+// A failed operation is returned as a *client.OperationError. attempt counts the creations of the Pod sent before, see createIdempotencyKey.
+func (b *createBatcher) create(ctx context.Context, req commonIL.PodCreateRequests, attempt int) error {
+	pending := pendingCreate{
+		ctx:    ctx,
+		req:    commonIL.CreateOperationRequest{PodCreateRequests: req, IdempotencyKey: createIdempotencyKey(req, attempt)},
+		result: make(chan submitResult, 1),
+	}
+
+	b.mu.Lock()
+	if b.window <= 0 || b.unsupported {
+		b.mu.Unlock()
+		b.submitOne(pending)
+	} else {
+		b.pending = append(b.pending, pending)
+		switch {
+		case len(b.pending) >= b.maxSize:
+			if b.timer != nil {
+				b.timer.Stop()
+			}
+			go b.submit(b.take())
+		case len(b.pending) == 1:
+			b.timer = time.AfterFunc(b.window, b.flush)
+		}
+		b.mu.Unlock()
+	}
+
+	var submitted submitResult
+	select {
+	case submitted = <-pending.result:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if submitted.err != nil {
+		return submitted.err
+	}
+
+	log.G(ctx).Info("Pod " + req.Pod.Namespace + "/" + req.Pod.Name + " submitted to InterLink as operation " + submitted.op.ID)
+	return retryUnknown(ctx, func() error {
+		_, err := b.client.WaitOperation(ctx, submitted.op.ID, operationPollInterval)
+		return err
+	})
+}
+
+// take empties the pending batch. It must be called holding mu.
+func (b *createBatcher) take() []pendingCreate {
+	batch := b.pending
+	b.pending = nil
+	b.timer = nil
+	return batch
+}
+
+// flush submits the pending batch once the window is over
+func (b *createBatcher) flush() {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+
+	if len(batch) > 0 {
+		b.submit(batch)
+	}
+}
+
+// submit submits the creations of the batch with a single InterLink call and hands every Pod its own operation
+func (b *createBatcher) submit(batch []pendingCreate) {
+	if len(batch) == 1 {
+		b.submitOne(batch[0])
+		return
+	}
+
+	reqs := make([]commonIL.CreateOperationRequest, len(batch))
+	for i, pending := range batch {
+		reqs[i] = pending.req
+	}
+
+	// the batch outlives the single Pod creations, so it is not cancelled with any of them
+	ctx := context.WithoutCancel(batch[0].ctx)
+	log.G(ctx).Info("Submitting the creation of " + strconv.Itoa(len(batch)) + " Pods with a single InterLink call")
+	var ops []commonIL.Operation
+	err := retryUnknown(ctx, func() error {
+		var err error
+		ops, err = b.client.SubmitCreateBatch(ctx, reqs)
+		return err
+	})
+
+	var statusErr *client.StatusError
+	if errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusMethodNotAllowed) {
+		log.G(ctx).Warning("InterLink does not serve batches of operations, submitting Pods one at a time")
+		b.mu.Lock()
+		b.unsupported = true
+		b.mu.Unlock()
+
+		for _, pending := range batch {
+			go b.submitOne(pending)
+		}
+		return
+	}
+
+	for i, pending := range batch {
+		if err != nil {
+			pending.result <- submitResult{err: err}
+		} else {
+			pending.result <- submitResult{op: &ops[i]}
+		}
+	}
+}
+
+func (b *createBatcher) submitOne(pending pendingCreate) {
+	var op *commonIL.Operation
+	err := retryUnknown(pending.ctx, func() error {
+		var err error
+		op, err = b.client.SubmitCreate(pending.ctx, pending.req.PodCreateRequests, pending.req.IdempotencyKey)
+		return err
+	})
+	pending.result <- submitResult{op: op, err: err}
+}
@@ -1,18 +1,46 @@
 package virtualkubelet
 
+import commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+
 // VirtualKubeletConfig holds the whole configuration
 type VirtualKubeletConfig struct {
-	Interlinkurl      string `yaml:"InterlinkURL"`
-	Interlinkport     string `yaml:"InterlinkPort"`
-	VKConfigPath      string `yaml:"VKConfigPath"`
-	VKTokenFile       string `yaml:"VKTokenFile"`
-	ServiceAccount    string `yaml:"ServiceAccount"`
-	Namespace         string `yaml:"Namespace"`
-	PodIP             string `yaml:"PodIP"`
-	VerboseLogging    bool   `yaml:"VerboseLogging"`
-	ErrorsOnlyLogging bool   `yaml:"ErrorsOnlyLogging"`
-	CPU               string `yaml:"cpu,omitempty"`
-	Memory            string `yaml:"memory,omitempty"`
-	Pods              string `yaml:"pods,omitempty"`
-	GPU               string `yaml:"nvidia.com/gpu,omitempty"`
+	Interlinkurl      string             `yaml:"InterlinkURL"`
+	Interlinkport     string             `yaml:"InterlinkPort"`
+	VKConfigPath      string             `yaml:"VKConfigPath"`
+	VKTokenFile       string             `yaml:"VKTokenFile"`
+	ServiceAccount    string             `yaml:"ServiceAccount"`
+	Namespace         string             `yaml:"Namespace"`
+	PodIP             string             `yaml:"PodIP"`
+	VerboseLogging    bool               `yaml:"VerboseLogging"`
+	ErrorsOnlyLogging bool               `yaml:"ErrorsOnlyLogging"`
+	CPU               string             `yaml:"cpu,omitempty"`
+	Memory            string             `yaml:"memory,omitempty"`
+	Pods              string             `yaml:"pods,omitempty"`
+	GPU               string             `yaml:"nvidia.com/gpu,omitempty"`
+	TLS               commonIL.TLSConfig `yaml:"TLS"`
+	// CreateBatchWindow is how long Pod creations are held to be sent to InterLink together, e.g. "200ms". "0s" disables batching.
+	CreateBatchWindow string `yaml:"CreateBatchWindow"`
+	// CreateBatchSize is the largest number of Pods sent to InterLink in a single creation call
+	CreateBatchSize int `yaml:"CreateBatchSize"`
+	// VolumeMappings translate the PersistentVolumeClaims and hostPath volumes of the Pods to the storage of the remote site.
+	// The first mapping matching a volume is used. Pods mounting a claim no mapping matches fail,
+	// hostPath volumes no mapping matches are passed to the plugin with their path alone.
+	VolumeMappings []VolumeMapping `yaml:"VolumeMappings"`
+	// DependencyTimeout is how long a Pod waits for its ConfigMaps, Secrets and claims before failing, e.g. "5m"
+	DependencyTimeout string `yaml:"DependencyTimeout"`
+}
+
+// VolumeMapping maps volumes to a remote path or to a volume of the plugin, described by Driver and Options.
+// Claims are matched on every set field among ClaimName, StorageClass and Annotations, the annotations of the claim;
+// hostPath volumes are matched on HostPath, the path or a parent path of the volume.
+// RemotePath may contain the {namespace} and {claim} placeholders. For hostPath volumes, the path of the volume below HostPath is appended to it.
+type VolumeMapping struct {
+	ClaimName    string            `yaml:"ClaimName"`
+	StorageClass string            `yaml:"StorageClass"`
+	Annotations  map[string]string `yaml:"Annotations"`
+	HostPath     string            `yaml:"HostPath"`
+	RemotePath   string            `yaml:"RemotePath"`
+	Driver       string            `yaml:"Driver"`
+	Options      map[string]string `yaml:"Options"`
+	ReadOnly     bool              `yaml:"ReadOnly"`
 }
@@ -0,0 +1,301 @@
+package virtualkubelet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// DefaultDependencyTimeout is how long a Pod waits for its ConfigMaps, Secrets and claims, when DependencyTimeout is unset
+const DefaultDependencyTimeout = 5 * time.Minute
+
+// dependencyRecheckInterval is how often the dependencies of a waiting Pod are checked again without any event,
+// for the objects no informer is watched for, such as PersistentVolumeClaims
+const dependencyRecheckInterval = 10 * time.Second
+
+// PodDependenciesReady is the condition of the Pods whose ConfigMaps, Secrets and volumes are all available.
+// It is False, with the missing objects in the message, while the creation waits for them.
+const PodDependenciesReady v1.PodConditionType = "DependenciesReady"
+
+// errDependencyTimeout is returned when the dependencies of a Pod are still missing after DependencyTimeout
+var errDependencyTimeout = errors.New("timed out waiting for the dependencies of the Pod")
+
+// dependencies tracks the changes of the ConfigMaps and Secrets watched by the informers, to wake the Pods waiting for them,
+// and the Pods being created, to stop waiting when they are deleted
+type dependencies struct {
+	configMaps corelisters.ConfigMapLister
+	secrets    corelisters.SecretLister
+
+	mu        sync.Mutex
+	changed   map[string]chan struct{}
+	creations map[string]*creation
+}
+
+// creation is a Pod creation in progress. Its address tells it apart from a later creation of a Pod with the same key.
+type creation struct {
+	cancel context.CancelFunc
+}
+
+func newDependencies() *dependencies {
+	return &dependencies{changed: map[string]chan struct{}{}, creations: map[string]*creation{}}
+}
+
+// changes returns a channel closed at the next change of a ConfigMap or Secret in the namespace
+func (d *dependencies) changes(namespace string) <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ch, ok := d.changed[namespace]
+	if !ok {
+		ch = make(chan struct{})
+		d.changed[namespace] = ch
+	}
+	return ch
+}
+
+func (d *dependencies) notify(obj interface{}) {
+	object, ok := obj.(metav1.Object)
+	if !ok {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if ch, ok := d.changed[object.GetNamespace()]; ok {
+		close(ch)
+		delete(d.changed, object.GetNamespace())
+	}
+}
+
+// track returns a context cancelled when the creation of the Pod with the given key is cancelled, and the function to call once it is over.
+// The function forgets the creation only if it is still the tracked one, since the Pod may have been deleted and created again meanwhile.
+func (d *dependencies) track(ctx context.Context, key string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &creation{cancel: cancel}
+	d.mu.Lock()
+	d.creations[key] = c
+	d.mu.Unlock()
+	return ctx, func() {
+		d.mu.Lock()
+		if d.creations[key] == c {
+			delete(d.creations, key)
+		}
+		d.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancel stops the creation of the Pod with the given key, if still in progress
+func (d *dependencies) cancel(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if c, ok := d.creations[key]; ok {
+		c.cancel()
+		delete(d.creations, key)
+	}
+}
+
+// WatchDependencies makes the provider read ConfigMaps and Secrets from the informers, and wake the Pods waiting for them on every change.
+// It must be called before the informers are started. Without informers, the provider gets them from the API server instead.
+func (p *VirtualKubeletProvider) WatchDependencies(configMaps coreinformers.ConfigMapInformer, secrets coreinformers.SecretInformer) {
+	p.dependencies.configMaps = configMaps.Lister()
+	p.dependencies.secrets = secrets.Lister()
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: p.dependencies.notify,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldObject, oldOk := oldObj.(metav1.Object)
+			newObject, newOk := newObj.(metav1.Object)
+			// resyncs deliver unchanged objects: nobody is waiting for them
+			if oldOk && newOk && oldObject.GetResourceVersion() == newObject.GetResourceVersion() {
+				return
+			}
+			p.dependencies.notify(newObj)
+		},
+	}
+	configMaps.Informer().AddEventHandler(handler)
+	secrets.Informer().AddEventHandler(handler)
+}
+
+func (p *VirtualKubeletProvider) getConfigMap(ctx context.Context, namespace, name string) (*v1.ConfigMap, error) {
+	if p.dependencies.configMaps != nil {
+		return p.dependencies.configMaps.ConfigMaps(namespace).Get(name)
+	}
+	return p.clientSet.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (p *VirtualKubeletProvider) getSecret(ctx context.Context, namespace, name string) (*v1.Secret, error) {
+	if p.dependencies.secrets != nil {
+		return p.dependencies.secrets.Secrets(namespace).Get(name)
+	}
+	return p.clientSet.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// waitForDependencies returns the creation request of the Pod once all its ConfigMaps, Secrets and volumes are available.
+// It checks them again on every change of a ConfigMap or Secret of the namespace, and at least every dependencyRecheckInterval.
+// The projected volumes are rendered, and their service account tokens requested, only once everything else is available,
+// and each of them only once.
+// While waiting, the Pod is Initializing with the PodDependenciesReady condition False, listing what is missing.
+// It returns an error wrapping errDependencyTimeout after the DependencyTimeout, ctx.Err() if the creation is cancelled,
+// and at once the errors no wait can fix, such as errNoVolumeMapping.
+func (p *VirtualKubeletProvider) waitForDependencies(ctx context.Context, pod *v1.Pod) (commonIL.PodCreateRequests, error) {
+	timeout := time.NewTimer(p.dependencyTimeout)
+	defer timeout.Stop()
+	recheck := time.NewTicker(dependencyRecheckInterval)
+	defer recheck.Stop()
+
+	waiting := false
+	rendered := map[string]commonIL.ProjectedVolume{}
+	for {
+		changed := p.dependencies.changes(pod.Namespace)
+		req, err := p.resolveDependencies(ctx, pod)
+		if err == nil {
+			err = p.renderVolumes(ctx, pod, &req, rendered)
+		}
+		if err == nil {
+			if waiting {
+				setPodCondition(pod, PodDependenciesReady, v1.ConditionTrue, "DependenciesResolved", "")
+				pod.Status.Phase = v1.PodPending
+				p.UpdatePod(ctx, pod)
+			}
+			return req, nil
+		}
+		if errors.Is(err, errNoVolumeMapping) {
+			return req, err
+		}
+
+		missing := strings.ReplaceAll(err.Error(), "\n", "; ")
+		if setPodCondition(pod, PodDependenciesReady, v1.ConditionFalse, "WaitingForDependencies", missing) || !waiting {
+			log.G(ctx).Warning("Waiting for the dependencies of pod " + pod.Namespace + "/" + pod.Name + ": " + missing)
+			pod.Status.Phase = "Initializing"
+			p.UpdatePod(ctx, pod)
+		}
+		waiting = true
+
+		select {
+		case <-changed:
+		case <-recheck.C:
+		case <-ctx.Done():
+			return req, ctx.Err()
+		case <-timeout.C:
+			return req, fmt.Errorf("%w after %s: %w", errDependencyTimeout, p.dependencyTimeout, err)
+		}
+	}
+}
+
+// resolveDependencies builds the creation request of the Pod, with the ConfigMaps, Secrets, mapped volumes and the environment.
+// The projected volumes are only checked, without requesting any token: renderVolumes renders them once they are all available.
+// The returned error joins every missing dependency, so that they can be reported together.
+func (p *VirtualKubeletProvider) resolveDependencies(ctx context.Context, pod *v1.Pod) (commonIL.PodCreateRequests, error) {
+	req := commonIL.PodCreateRequests{Pod: *pod}
+
+	var errs []error
+	for _, volume := range pod.Spec.Volumes {
+		switch {
+		case volume.ConfigMap != nil:
+			cfgmap, err := p.getConfigMap(ctx, pod.Namespace, volume.ConfigMap.Name)
+			if err != nil && isOptional(volume.ConfigMap.Optional) {
+				// a missing optional ConfigMap is mounted as an empty volume, as the kubelet does
+				log.G(ctx).Debug("Optional ConfigMap " + volume.ConfigMap.Name + " for pod " + pod.Name + " not found, mounting it empty")
+			} else if err != nil {
+				errs = append(errs, fmt.Errorf("ConfigMap %s of volume %s: %w", volume.ConfigMap.Name, volume.Name, err))
+			} else if !hasConfigMap(req.ConfigMaps, cfgmap.Name) {
+				req.ConfigMaps = append(req.ConfigMaps, *cfgmap)
+			}
+		case volume.Secret != nil:
+			secret, err := p.getSecret(ctx, pod.Namespace, volume.Secret.SecretName)
+			if err != nil && isOptional(volume.Secret.Optional) {
+				log.G(ctx).Debug("Optional Secret " + volume.Secret.SecretName + " for pod " + pod.Name + " not found, mounting it empty")
+			} else if err != nil {
+				errs = append(errs, fmt.Errorf("Secret %s of volume %s: %w", volume.Secret.SecretName, volume.Name, err))
+			} else if !hasSecret(req.Secrets, secret.Name) {
+				req.Secrets = append(req.Secrets, *secret)
+			}
+		case volume.Projected != nil || volume.DownwardAPI != nil:
+			_, err := p.renderVolume(ctx, pod, volume, false)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		case volume.PersistentVolumeClaim != nil || volume.HostPath != nil:
+			mapped, err := p.mapVolume(ctx, pod, volume)
+			if errors.Is(err, errNoVolumeMapping) {
+				return req, err
+			} else if err != nil {
+				errs = append(errs, err)
+			} else {
+				req.MappedVolumes = append(req.MappedVolumes, mapped)
+			}
+		}
+	}
+
+	err := p.resolveEnv(ctx, pod, &req)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return req, errors.Join(errs...)
+}
+
+// renderVolumes adds the projected and downwardAPI volumes of the Pod to the creation request, with their service account tokens.
+// The volumes already rendered by a previous call are taken from rendered, so that their tokens are not requested again.
+func (p *VirtualKubeletProvider) renderVolumes(ctx context.Context, pod *v1.Pod, req *commonIL.PodCreateRequests, rendered map[string]commonIL.ProjectedVolume) error {
+	var errs []error
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Projected == nil && volume.DownwardAPI == nil {
+			continue
+		}
+		projected, ok := rendered[volume.Name]
+		if !ok {
+			var err error
+			projected, err = p.renderVolume(ctx, pod, volume, true)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			rendered[volume.Name] = projected
+		}
+		req.ProjectedVolumes = append(req.ProjectedVolumes, projected)
+	}
+	return errors.Join(errs...)
+}
+
+// setPodCondition sets the condition of the given type on the Pod, and returns whether it changed.
+// The transition time is only updated when the status changes.
+func setPodCondition(pod *v1.Pod, conditionType v1.PodConditionType, status v1.ConditionStatus, reason, message string) bool {
+	now := metav1.Now()
+	for i := range pod.Status.Conditions {
+		condition := &pod.Status.Conditions[i]
+		if condition.Type != conditionType {
+			continue
+		}
+		if condition.Status == status && condition.Reason == reason && condition.Message == message {
+			return false
+		}
+		if condition.Status != status {
+			condition.LastTransitionTime = now
+		}
+		condition.Status = status
+		condition.Reason = reason
+		condition.Message = message
+		condition.LastProbeTime = now
+		return true
+	}
+	pod.Status.Conditions = append(pod.Status.Conditions, v1.PodCondition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastProbeTime:      now,
+		LastTransitionTime: now,
+	})
+	return true
+}
@@ -0,0 +1,268 @@
+package virtualkubelet
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/containerd/containerd/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// envResolver resolves the environment of the containers of a Pod, fetching every referenced ConfigMap and Secret once
+type envResolver struct {
+	p          *VirtualKubeletProvider
+	pod        *v1.Pod
+	configMaps map[string]*v1.ConfigMap
+	secrets    map[string]*v1.Secret
+}
+
+// resolveEnv fills req.Env with the environment of every container of the Pod, with the kubelet semantics: envFrom sources first,
+// in order, then env, each variable overriding the previous ones with the same name, and $(VAR) references expanded.
+// The ConfigMaps and Secrets referenced by the environment are added to req, if not already there.
+// A missing ConfigMap, Secret or key is returned as an error, unless optional, so that the creation waits for it.
+func (p *VirtualKubeletProvider) resolveEnv(ctx context.Context, pod *v1.Pod, req *commonIL.PodCreateRequests) error {
+	r := &envResolver{p: p, pod: pod, configMaps: map[string]*v1.ConfigMap{}, secrets: map[string]*v1.Secret{}}
+
+	env := make(map[string][]v1.EnvVar)
+	containers := append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, container := range containers {
+		if len(container.Env) == 0 && len(container.EnvFrom) == 0 {
+			continue
+		}
+		containerEnv, err := r.containerEnv(ctx, container)
+		if err != nil {
+			return fmt.Errorf("environment of container %s: %w", container.Name, err)
+		}
+		env[container.Name] = containerEnv
+	}
+
+	req.Env = env
+	for name, cfgmap := range r.configMaps {
+		if cfgmap != nil && !hasConfigMap(req.ConfigMaps, name) {
+			req.ConfigMaps = append(req.ConfigMaps, *cfgmap)
+		}
+	}
+	for name, secret := range r.secrets {
+		if secret != nil && !hasSecret(req.Secrets, name) {
+			req.Secrets = append(req.Secrets, *secret)
+		}
+	}
+	return nil
+}
+
+func (r *envResolver) containerEnv(ctx context.Context, container v1.Container) ([]v1.EnvVar, error) {
+	var names []string
+	values := make(map[string]string)
+	set := func(name string, value string) {
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+		values[name] = value
+	}
+
+	for _, source := range container.EnvFrom {
+		var data map[string]string
+		if source.ConfigMapRef != nil {
+			cfgmap, err := r.configMap(ctx, source.ConfigMapRef.Name, source.ConfigMapRef.Optional)
+			if err != nil {
+				return nil, err
+			}
+			if cfgmap != nil {
+				data = cfgmap.Data
+			}
+		} else if source.SecretRef != nil {
+			secret, err := r.secret(ctx, source.SecretRef.Name, source.SecretRef.Optional)
+			if err != nil {
+				return nil, err
+			}
+			if secret != nil {
+				data = secretData(secret)
+			}
+		}
+
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := source.Prefix + key
+			if errs := validation.IsEnvVarName(name); len(errs) > 0 {
+				log.G(ctx).Warning("Skipping invalid environment variable " + name + " of container " + container.Name + ": " + strings.Join(errs, ", "))
+				continue
+			}
+			set(name, data[key])
+		}
+	}
+
+	for _, envVar := range container.Env {
+		if envVar.ValueFrom == nil {
+			set(envVar.Name, expandEnv(envVar.Value, values))
+			continue
+		}
+
+		value, ok, err := r.valueFrom(ctx, container, envVar.ValueFrom)
+		if err != nil {
+			return nil, fmt.Errorf("variable %s: %w", envVar.Name, err)
+		}
+		if ok {
+			set(envVar.Name, value)
+		}
+	}
+
+	env := make([]v1.EnvVar, 0, len(names))
+	for _, name := range names {
+		env = append(env, v1.EnvVar{Name: name, Value: values[name]})
+	}
+	return env, nil
+}
+
+// valueFrom resolves a valueFrom source. It returns false for optional references to missing objects or keys, which leave the variable unset.
+func (r *envResolver) valueFrom(ctx context.Context, container v1.Container, source *v1.EnvVarSource) (string, bool, error) {
+	switch {
+	case source.ConfigMapKeyRef != nil:
+		ref := source.ConfigMapKeyRef
+		cfgmap, err := r.configMap(ctx, ref.Name, ref.Optional)
+		if err != nil || cfgmap == nil {
+			return "", false, err
+		}
+		value, ok := cfgmap.Data[ref.Key]
+		if !ok && !isOptional(ref.Optional) {
+			return "", false, fmt.Errorf("key %s not found in ConfigMap %s", ref.Key, ref.Name)
+		}
+		return value, ok, nil
+
+	case source.SecretKeyRef != nil:
+		ref := source.SecretKeyRef
+		secret, err := r.secret(ctx, ref.Name, ref.Optional)
+		if err != nil || secret == nil {
+			return "", false, err
+		}
+		value, ok := secretData(secret)[ref.Key]
+		if !ok && !isOptional(ref.Optional) {
+			return "", false, fmt.Errorf("key %s not found in Secret %s", ref.Key, ref.Name)
+		}
+		return value, ok, nil
+
+	case source.FieldRef != nil:
+		value, err := fieldRefValue(r.pod, source.FieldRef.FieldPath)
+		return value, err == nil, err
+
+	case source.ResourceFieldRef != nil:
+		containerName := source.ResourceFieldRef.ContainerName
+		if containerName == "" {
+			containerName = container.Name
+		}
+		var allocatable v1.ResourceList
+		if r.p.node != nil {
+			allocatable = r.p.node.Status.Allocatable
+		}
+		value, err := resourceFieldRefValue(r.pod, containerName, source.ResourceFieldRef, allocatable)
+		return value, err == nil, err
+	}
+	return "", false, nil
+}
+
+// configMap returns the ConfigMap, or nil if it is missing and optional
+func (r *envResolver) configMap(ctx context.Context, name string, optional *bool) (*v1.ConfigMap, error) {
+	if cfgmap, ok := r.configMaps[name]; ok {
+		return cfgmap, nil
+	}
+	cfgmap, err := r.p.getConfigMap(ctx, r.pod.Namespace, name)
+	if err != nil {
+		if isOptional(optional) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to find ConfigMap %s: %w", name, err)
+	}
+	r.configMaps[name] = cfgmap
+	return cfgmap, nil
+}
+
+// secret returns the Secret, or nil if it is missing and optional
+func (r *envResolver) secret(ctx context.Context, name string, optional *bool) (*v1.Secret, error) {
+	if secret, ok := r.secrets[name]; ok {
+		return secret, nil
+	}
+	secret, err := r.p.getSecret(ctx, r.pod.Namespace, name)
+	if err != nil {
+		if isOptional(optional) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to find Secret %s: %w", name, err)
+	}
+	r.secrets[name] = secret
+	return secret, nil
+}
+
+func secretData(secret *v1.Secret) map[string]string {
+	data := make(map[string]string, len(secret.Data)+len(secret.StringData))
+	for key, value := range secret.StringData {
+		data[key] = value
+	}
+	for key, value := range secret.Data {
+		data[key] = string(value)
+	}
+	return data
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
+
+func hasConfigMap(configMaps []v1.ConfigMap, name string) bool {
+	for _, cfgmap := range configMaps {
+		if cfgmap.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSecret(secrets []v1.Secret, name string) bool {
+	for _, secret := range secrets {
+		if secret.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// expandEnv expands the $(VAR) references to the variables defined so far, as the kubelet does: $$ escapes a $,
+// and references to undefined variables are left untouched
+func expandEnv(value string, env map[string]string) string {
+	var expanded strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i+1 == len(value) {
+			expanded.WriteByte(value[i])
+			continue
+		}
+
+		switch value[i+1] {
+		case '$':
+			expanded.WriteByte('$')
+			i++
+		case '(':
+			end := strings.IndexByte(value[i+2:], ')')
+			if end < 0 {
+				expanded.WriteString(value[i:])
+				return expanded.String()
+			}
+			name := value[i+2 : i+2+end]
+			if resolved, ok := env[name]; ok {
+				expanded.WriteString(resolved)
+			} else {
+				expanded.WriteString(value[i : i+3+end])
+			}
+			i += 2 + end
+		default:
+			expanded.WriteByte('$')
+		}
+	}
+	return expanded.String()
+}
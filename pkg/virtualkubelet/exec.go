@@ -0,0 +1,122 @@
+package virtualkubelet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/containerd/containerd/log"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	utilexec "k8s.io/utils/exec"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// RunInContainer executes a command in a container of the Pod, relaying the streams of the user to the sidecar through InterLink
+func (p *VirtualKubeletProvider) RunInContainer(ctx context.Context, namespace, podName, containerName string, cmd []string, attach api.AttachIO) error {
+	log.G(ctx).Infof("receive RunInContainer %q in %s/%s/%s", cmd, namespace, podName, containerName)
+	return p.streamContainer(ctx, namespace, podName, containerName, cmd, attach, p.interLinkClient.Exec)
+}
+
+// AttachToContainer attaches the streams of the user to the main process of a container of the Pod, through InterLink
+func (p *VirtualKubeletProvider) AttachToContainer(ctx context.Context, namespace, podName, containerName string, attach api.AttachIO) error {
+	log.G(ctx).Infof("receive AttachToContainer %s/%s/%s", namespace, podName, containerName)
+	return p.streamContainer(ctx, namespace, podName, containerName, nil, attach, p.interLinkClient.Attach)
+}
+
+// streamContainer opens the stream with open and relays stdin and resize events to it, and stdout and stderr from it, until the sidecar reports the outcome.
+// A non zero exit code is returned as an utilexec.ExitError, so that kubectl exits with the same code.
+func (p *VirtualKubeletProvider) streamContainer(ctx context.Context, namespace, podName, containerName string, cmd []string, attach api.AttachIO,
+	open func(context.Context, commonIL.ExecRequest) (*commonIL.StreamConn, error)) error {
+	pod, err := p.GetPod(ctx, namespace, podName)
+	if err != nil {
+		return err
+	}
+
+	conn, err := open(ctx, commonIL.ExecRequest{
+		Namespace:     namespace,
+		PodName:       podName,
+		PodUID:        string(pod.UID),
+		ContainerName: containerName,
+		Command:       cmd,
+		Stdin:         attach.Stdin() != nil,
+		Stdout:        attach.Stdout() != nil,
+		Stderr:        attach.Stderr() != nil,
+		TTY:           attach.TTY(),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to open the stream to %s/%s/%s: %s", namespace, podName, containerName, failureReason(err))
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		// interrupts ReadFrame when the user goes away
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	if attach.Stdin() != nil {
+		go func() {
+			stdin := conn.Writer(commonIL.StreamStdin)
+			_, err := io.Copy(stdin, attach.Stdin())
+			if err == nil {
+				// tells the command that stdin is over
+				stdin.Close()
+			}
+		}()
+	}
+	if attach.TTY() && attach.Resize() != nil {
+		go func() {
+			for {
+				select {
+				case size, ok := <-attach.Resize():
+					if !ok {
+						return
+					}
+					conn.WriteJSON(commonIL.StreamResize, commonIL.TermSize{Width: size.Width, Height: size.Height})
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		channel, payload, err := conn.ReadFrame()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if errors.Is(err, io.EOF) {
+			return errors.New("the stream closed without reporting the outcome of the command")
+		} else if err != nil {
+			return err
+		}
+
+		switch channel {
+		case commonIL.StreamStdout:
+			if attach.Stdout() != nil {
+				attach.Stdout().Write(payload)
+			}
+		case commonIL.StreamStderr:
+			if attach.Stderr() != nil {
+				attach.Stderr().Write(payload)
+			}
+		case commonIL.StreamStatus:
+			var result commonIL.ExecResult
+			err = json.Unmarshal(payload, &result)
+			if err != nil {
+				return fmt.Errorf("invalid outcome of the command: %w", err)
+			}
+			if result.Error != "" {
+				return errors.New(result.Error)
+			}
+			if result.ExitCode != 0 {
+				return utilexec.CodeExitError{Err: fmt.Errorf("command terminated with exit code %d", result.ExitCode), Code: result.ExitCode}
+			}
+			return nil
+		}
+	}
+}
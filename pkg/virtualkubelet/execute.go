@@ -1,16 +1,14 @@
 package virtualkubelet
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/containerd/containerd/log"
@@ -18,383 +16,300 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+	"github.com/intertwin-eu/interlink/pkg/interlink/client"
 )
 
-func doRequest(req *http.Request, token string) (*http.Response, error) {
-
-	req.Header.Add("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-	return http.DefaultClient.Do(req)
-
-}
+// newInterLinkClient builds the InterLink API client from the VK configuration.
+// The bearer token is read from VKTokenFile on every request, so refreshed tokens are picked up.
+// If TLS options are set, InterLink is verified against them and the client certificate is presented for mutual TLS.
+// TLS options with a plain http:// URL are an error, rather than silently talking clear text.
+func newInterLinkClient(config VirtualKubeletConfig) (*client.Client, error) {
+	interLinkEndpoint, err := client.Endpoint(config.Interlinkurl, config.Interlinkport)
+	if err != nil {
+		return nil, err
+	}
 
-func getSidecarEndpoint(ctx context.Context, interLinkURL string, interLinkPort string) string {
-	interLinkEndpoint := ""
-	if strings.HasPrefix(interLinkURL, "unix://") {
-		interLinkEndpoint = interLinkURL
-	} else if strings.HasPrefix(interLinkURL, "http://") {
-		interLinkEndpoint = interLinkURL + ":" + interLinkPort
-	} else {
-		log.G(ctx).Fatal("Sidecar URL should either start per unix:// or http://")
+	opts := []client.Option{client.WithAuthenticator(client.BearerTokenFile(config.VKTokenFile))}
+	if config.TLS.Enabled() && strings.HasPrefix(config.Interlinkurl, "http://") {
+		return nil, fmt.Errorf("TLS options are set but the InterLink URL %s is plain http://: use https:// instead", config.Interlinkurl)
+	} else if config.TLS.Enabled() {
+		tlsConfig, err := config.TLS.ClientTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.WithTLSConfig(tlsConfig))
 	}
-	return interLinkEndpoint
+
+	return client.NewClient(interLinkEndpoint, opts...), nil
 }
 
 // PingInterLink pings the InterLink API and returns true if there's an answer. The second return value is given by the answer provided by the API.
-func PingInterLink(ctx context.Context, config VirtualKubeletConfig) (bool, int, error) {
-	interLinkEndpoint := getSidecarEndpoint(ctx, config.Interlinkurl, config.Interlinkport)
-	log.G(ctx).Info("Pinging: " + interLinkEndpoint + "/pinglink")
-	retVal := -1
-	req, err := http.NewRequest(http.MethodPost, interLinkEndpoint+"/pinglink", nil)
-
+func PingInterLink(ctx context.Context, ilClient *client.Client) (bool, int, error) {
+	log.G(ctx).Info("Pinging: " + ilClient.Endpoint() + client.PingRoute)
+	retVal, err := ilClient.Ping(ctx)
 	if err != nil {
+		var statusErr *client.StatusError
+		if errors.As(err, &statusErr) {
+			log.G(ctx).Error("server error: " + fmt.Sprint(statusErr.StatusCode))
+			return false, retVal, nil
+		}
 		log.G(ctx).Error(err)
+		return false, retVal, err
 	}
+	return true, retVal, nil
+}
 
-	token, err := os.ReadFile(config.VKTokenFile) // just pass the file name
+// LogRetrieval performs a REST call to the InterLink API when the user ask for a log retrieval. Compared to create/delete/status request, a way smaller struct is marshalled and sent.
+// This struct only includes a minimum data set needed to identify the job/container to get the logs from.
+// Returns the live response stream, which keeps delivering logs as they come when Follow is set, and/or the first encountered error.
+// The stream is bound to ctx: cancelling it (e.g. on client disconnection) interrupts the InterLink call.
+func LogRetrieval(ctx context.Context, ilClient *client.Client, logsRequest commonIL.LogStruct) (io.ReadCloser, error) {
+	logs, err := ilClient.GetLogs(ctx, logsRequest)
 	if err != nil {
 		log.G(ctx).Error(err)
-		return false, retVal, err
-	}
-	req.Header.Add("Authorization", "Bearer "+string(token))
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return false, retVal, err
+		return nil, err
 	}
+	return logs, nil
+}
 
-	if resp.StatusCode == http.StatusOK {
-		retBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.G(ctx).Error(err)
-			return false, retVal, err
-		}
-		retVal, err = strconv.Atoi(string(retBytes))
-		if err != nil {
-			log.G(ctx).Error(err)
-			return false, retVal, err
+// RemoteExecution is called by the VK everytime a Pod is being registered or deleted to/from the VK.
+// Depending on the mode (CREATE/DELETE), it performs different actions, making different REST calls.
+// Projected and downwardAPI volumes are rendered and the environment of the containers resolved here, as the sidecar has no access to the cluster.
+// Note: for the CREATE mode, the function waits for every missing ConfigMap, Secret and claim up to the DependencyTimeout, 5 minutes by default,
+// checking them again on every change seen by the informers. Then, or if the Pod is deleted meanwhile, it gives up.
+// Creations and deletions are submitted as InterLink operations, with an idempotency key derived from the Pod UID, and waited for:
+// submits repeated after a network error or a VK restart get the operation already there, so the remote job is never created twice.
+// A creation failing without being refused leaves the Pod Pending: the status loop sends it again once the sidecar reports the Pod as not found.
+func RemoteExecution(ctx context.Context, config VirtualKubeletConfig, p *VirtualKubeletProvider, pod *v1.Pod, mode int8) error {
+
+	switch mode {
+	case CREATE:
+		return p.remoteCreate(ctx, pod, 0)
+
+	case DELETE:
+		if pod.Status.Phase != "Initializing" {
+			var op *commonIL.Operation
+			err := retryUnknown(ctx, func() error {
+				var err error
+				op, err = p.interLinkClient.SubmitDelete(ctx, pod, "delete-"+string(pod.UID))
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			err = retryUnknown(ctx, func() error {
+				_, err := p.interLinkClient.WaitOperation(ctx, op.ID, operationPollInterval)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			log.G(ctx).Info("Pod " + pod.Namespace + "/" + pod.Name + " deleted by operation " + op.ID)
 		}
-		return true, retVal, nil
-	} else {
-		log.G(ctx).Error("server error: " + fmt.Sprint(resp.StatusCode))
-		return false, retVal, nil
 	}
+	return nil
 }
 
-// updateCacheRequest is called when the VK receives the status of a pod already deleted. It performs a REST call InterLink API to update the cache deleting that pod from the cached structure
-func updateCacheRequest(ctx context.Context, config VirtualKubeletConfig, uid string, token string) error {
-	bodyBytes := []byte(uid)
-
-	interLinkEndpoint := getSidecarEndpoint(ctx, config.Interlinkurl, config.Interlinkport)
-	reader := bytes.NewReader(bodyBytes)
-	req, err := http.NewRequest(http.MethodPost, interLinkEndpoint+"/updateCache", reader)
+// remoteCreate waits for the dependencies of the Pod and creates it through InterLink, see RemoteExecution.
+// attempt counts the creations of the Pod sent before, once the sidecar reported the Pod as not found after a failure.
+func (p *VirtualKubeletProvider) remoteCreate(ctx context.Context, pod *v1.Pod, attempt int) error {
+	_, err := p.clientSet.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
 	if err != nil {
-		log.L.Error(err)
-		return err
+		log.G(ctx).Warning("Deleted Pod before actual creation")
+		return nil
 	}
 
-	req.Header.Add("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		log.L.Error(err)
+	req, err := p.waitForDependencies(ctx, pod)
+	if errors.Is(err, context.Canceled) {
+		log.G(ctx).Info("Pod " + pod.Namespace + "/" + pod.Name + " deleted while waiting for its dependencies")
+		return nil
+	} else if errors.Is(err, errNoVolumeMapping) {
+		// the remote site can't provide the volume: the Pod fails instead of running without it
+		p.failPod(ctx, pod, "VolumeMappingNotFound", err.Error())
+		return err
+	} else if err != nil {
+		setPodCondition(pod, PodDependenciesReady, v1.ConditionFalse, "DependencyTimeout", strings.ReplaceAll(err.Error(), "\n", "; "))
+		p.failPod(ctx, pod, "CFGMaps/Secrets not found", err.Error())
 		return err
 	}
-	statusCode := resp.StatusCode
 
-	if statusCode != http.StatusOK {
-		return errors.New("Unexpected error occured while updating InterLink cache. Status code: " + strconv.Itoa(resp.StatusCode) + ". Check InterLink's logs for further informations")
+	// once sent, the creation is no longer cancelled by the deletion of the Pod: the remote Pod is deleted instead
+	err = p.createBatcher.create(context.WithoutCancel(ctx), req, attempt)
+	if err != nil && createRejected(err) {
+		// the Pod won't be created, so the real reason is reported on its status instead of only in the VK logs
+		p.failPod(ctx, pod, "InterLinkCreateFailed", failureReason(err))
+		return err
+	} else if err != nil {
+		// the remote job may exist anyway: the Pod stays Pending, a terminal phase would make its controller start a replacement.
+		// The creation is sent again if the sidecar reports the Pod as not found.
+		pod.Status.Message = "InterLink create failed, the outcome is unknown: " + failureReason(err)
+		p.failedCreates.add(string(pod.UID), attempt)
+		p.UpdatePod(ctx, pod)
+		return err
 	}
-
-	return err
+	log.G(ctx).Info("Pod " + pod.Namespace + "/" + pod.Name + " created")
+	return nil
 }
 
-// createRequest performs a REST call to the InterLink API when a Pod is registered to the VK. It Marshals the pod with already retrieved ConfigMaps and Secrets and sends it to InterLink.
-// Returns the call response expressed in bytes and/or the first encountered error
-func createRequest(ctx context.Context, config VirtualKubeletConfig, pod commonIL.PodCreateRequests, token string) ([]byte, error) {
-	interLinkEndpoint := getSidecarEndpoint(ctx, config.Interlinkurl, config.Interlinkport)
-	var returnValue, _ = json.Marshal(commonIL.PodStatus{})
+// createAttempts is how many times the creation of a Pod is sent while the sidecar reports it as not found after a failure
+const createAttempts = 3
 
-	bodyBytes, err := json.Marshal(pod)
-	if err != nil {
-		log.L.Error(err)
-		return nil, err
-	}
-	reader := bytes.NewReader(bodyBytes)
-	req, err := http.NewRequest(http.MethodPost, interLinkEndpoint+"/create", reader)
-	if err != nil {
-		log.L.Error(err)
-		return nil, err
-	}
+// failedCreates holds, per Pod UID, the last attempt of the creations that failed without being refused, e.g. because the sidecar
+// could not be reached. The status loop sends them again once the sidecar reports the Pod as not found.
+type failedCreates struct {
+	mu       sync.Mutex
+	attempts map[string]int
+}
 
-	resp, err := doRequest(req, token)
-	if err != nil {
-		log.L.Error(err)
-		return nil, err
-	}
-	statusCode := resp.StatusCode
+func newFailedCreates() *failedCreates {
+	return &failedCreates{attempts: map[string]int{}}
+}
 
-	if statusCode != http.StatusOK {
-		return nil, errors.New("Unexpected error occured while creating Pods. Status code: " + strconv.Itoa(resp.StatusCode) + ". Check InterLink's logs for further informations")
-	} else {
-		returnValue, err = io.ReadAll(resp.Body)
-		if err != nil {
-			log.L.Error(err)
-			return nil, err
-		}
-	}
+func (f *failedCreates) add(uid string, attempt int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts[uid] = attempt
+}
 
-	return returnValue, nil
+// take forgets the failed creation of the Pod, returning its attempt and true if there was one
+func (f *failedCreates) take(uid string) (int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	attempt, ok := f.attempts[uid]
+	delete(f.attempts, uid)
+	return attempt, ok
 }
 
-// deleteRequest performs a REST call to the InterLink API when a Pod is deleted from the VK. It Marshals the standard v1.Pod struct and sends it to InterLink.
-// Returns the call response expressed in bytes and/or the first encountered error
-func deleteRequest(ctx context.Context, config VirtualKubeletConfig, pod *v1.Pod, token string) ([]byte, error) {
-	interLinkEndpoint := getSidecarEndpoint(ctx, config.Interlinkurl, config.Interlinkport)
-	bodyBytes, err := json.Marshal(pod)
-	if err != nil {
-		log.G(context.Background()).Error(err)
-		return nil, err
-	}
-	reader := bytes.NewReader(bodyBytes)
-	req, err := http.NewRequest(http.MethodDelete, interLinkEndpoint+"/delete", reader)
-	if err != nil {
-		log.G(context.Background()).Error(err)
-		return nil, err
+// resendCreate sends the creation of the Pod again, once the sidecar reported it as not found after a failed creation,
+// or fails the Pod after createAttempts creations
+func (p *VirtualKubeletProvider) resendCreate(ctx context.Context, pod *v1.Pod, attempt int) {
+	if attempt >= createAttempts {
+		p.failPod(ctx, pod, "InterLinkCreateFailed", "the Pod is still not found on the sidecar after "+strconv.Itoa(attempt)+" failed creations. "+pod.Status.Message)
+		return
 	}
-
-	resp, err := doRequest(req, token)
+	key, err := buildKey(pod)
 	if err != nil {
-		log.G(context.Background()).Error(err)
-		return nil, err
+		log.G(ctx).Error(err)
+		return
 	}
 
-	statusCode := resp.StatusCode
-
-	if statusCode != http.StatusOK {
-		return nil, errors.New("Unexpected error occured while deleting Pods. Status code: " + strconv.Itoa(resp.StatusCode) + ". Check InterLink's logs for further informations")
-	} else {
-		returnValue, err := io.ReadAll(resp.Body)
+	log.G(ctx).Info("Pod " + pod.Namespace + "/" + pod.Name + " not found on the sidecar after a failed creation, sending it again")
+	creationCtx, done := p.dependencies.track(context.WithoutCancel(ctx), key)
+	go func() {
+		defer done()
+		err := p.remoteCreate(creationCtx, pod, attempt)
 		if err != nil {
-			log.G(context.Background()).Error(err)
-			return nil, err
-		}
-		log.G(context.Background()).Info(string(returnValue))
-		var response []commonIL.PodStatus
-		err = json.Unmarshal(returnValue, &response)
-		if err != nil {
-			log.G(context.Background()).Error(err)
-			return nil, err
+			log.G(ctx).Error(err)
 		}
-		return returnValue, nil
-	}
+	}()
 }
 
-// statusRequest performs a REST call to the InterLink API when the VK needs an update on its Pods' status. A Marshalled slice of v1.Pod is sent to the InterLink API,
-// to query the below plugin for their status.
-// Returns the call response expressed in bytes and/or the first encountered error
-func statusRequest(ctx context.Context, config VirtualKubeletConfig, podsList []*v1.Pod, token string) ([]byte, error) {
-	var returnValue []byte
-	interLinkEndpoint := getSidecarEndpoint(ctx, config.Interlinkurl, config.Interlinkport)
-
-	bodyBytes, err := json.Marshal(podsList)
-	if err != nil {
-		log.L.Error(err)
-		return nil, err
-	}
-	reader := bytes.NewReader(bodyBytes)
-	req, err := http.NewRequest(http.MethodGet, interLinkEndpoint+"/status", reader)
-	if err != nil {
-		log.L.Error(err)
-		return nil, err
-	}
-
-	//log.L.Println(string(bodyBytes))
-
-	resp, err := doRequest(req, token)
-	if err != nil {
-		return nil, err
-	}
+// operationRetries is how many times a call of the operations API is sent again when it fails without an answer from InterLink
+const operationRetries = 3
+
+// retryUnknown calls f, and calls it again with a growing delay while it fails without an answer from InterLink, e.g. on network errors.
+// It must only wrap idempotent calls, such as the submits with an idempotency key and the reads of the operations.
+func retryUnknown(ctx context.Context, f func() error) error {
+	delay := operationPollInterval
+	for attempt := 0; ; attempt++ {
+		err := f()
+		var statusErr *client.StatusError
+		var opErr *client.OperationError
+		if err == nil || errors.As(err, &statusErr) || errors.As(err, &opErr) || ctx.Err() != nil || attempt == operationRetries {
+			return err
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("Unexpected error occured while getting status. Status code: " + strconv.Itoa(resp.StatusCode) + ". Check InterLink's logs for further informations")
-	} else {
-		returnValue, err = io.ReadAll(resp.Body)
-		if err != nil {
-			log.L.Error(err)
-			return nil, err
+		log.G(ctx).Warning("InterLink call failed, retrying in " + delay.String() + ": " + err.Error())
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
 		}
+		delay *= 2
 	}
-
-	return returnValue, nil
 }
 
-// LogRetrieval performs a REST call to the InterLink API when the user ask for a log retrieval. Compared to create/delete/status request, a way smaller struct is marshalled and sent.
-// This struct only includes a minimum data set needed to identify the job/container to get the logs from.
-// Returns the call response and/or the first encountered error
-func LogRetrieval(ctx context.Context, config VirtualKubeletConfig, logsRequest commonIL.LogStruct) (io.ReadCloser, error) {
-	interLinkEndpoint := getSidecarEndpoint(ctx, config.Interlinkurl, config.Interlinkport)
-	b, err := os.ReadFile(config.VKTokenFile) // just pass the file name
-	if err != nil {
-		log.G(ctx).Fatal(err)
+// failPod marks the Pod and its containers as failed, with the given reason and message
+func (p *VirtualKubeletProvider) failPod(ctx context.Context, pod *v1.Pod, reason, message string) {
+	pod.Status.Phase = v1.PodFailed
+	pod.Status.Reason = reason
+	pod.Status.Message = message
+	for i := range pod.Status.ContainerStatuses {
+		pod.Status.ContainerStatuses[i].Ready = false
 	}
-	token := string(b)
+	p.UpdatePod(ctx, pod)
+}
 
-	bodyBytes, err := json.Marshal(logsRequest)
-	if err != nil {
-		log.G(ctx).Error(err)
-		return nil, err
-	}
-	reader := bytes.NewReader(bodyBytes)
-	req, err := http.NewRequest(http.MethodGet, interLinkEndpoint+"/getLogs", reader)
-	if err != nil {
-		log.G(ctx).Error(err)
-		return nil, err
+// createRejected reports whether InterLink or the sidecar definitely refused to create the Pod: a 4xx status code, or a failure reported
+// for the Pod itself. Transport errors, timeouts and 5xx status codes leave the outcome unknown, as the Pod may have been created anyway,
+// as do the failed operations InterLink marks with OutcomeUnknown, and 409, answered while a previous creation has an unknown outcome.
+func createRejected(err error) bool {
+	var opErr *client.OperationError
+	if errors.As(err, &opErr) && opErr.Operation.OutcomeUnknown {
+		return false
 	}
-
-	//log.G(ctx).Println(string(bodyBytes))
-
-	resp, err := doRequest(req, token)
-	if err != nil {
-		log.G(ctx).Error(err)
-		return nil, err
+	var statusErr *client.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 && statusErr.StatusCode != http.StatusConflict &&
+			statusErr.StatusCode != http.StatusRequestTimeout && statusErr.StatusCode != http.StatusTooManyRequests
 	}
-
-	if resp.StatusCode != http.StatusOK {
-		log.G(ctx).Info(resp.Body)
-		return nil, errors.New("Unexpected error occured while getting logs. Status code: " + strconv.Itoa(resp.StatusCode) + ". Check InterLink's logs for further informations")
-	} else {
-		return resp.Body, nil
+	var apiErr *commonIL.Error
+	if errors.As(err, &apiErr) {
+		// 502, 503 and 504 report a sidecar that could not be reached or did not tell the outcome
+		return apiErr.Code < http.StatusBadGateway
 	}
+	return false
 }
 
-// RemoteExecution is called by the VK everytime a Pod is being registered or deleted to/from the VK.
-// Depending on the mode (CREATE/DELETE), it performs different actions, making different REST calls.
-// Note: for the CREATE mode, the function gets stuck up to 5 minutes waiting for every missing ConfigMap/Secret.
-// If after 5m they are not still available, the function errors out
-func RemoteExecution(ctx context.Context, config VirtualKubeletConfig, p *VirtualKubeletProvider, pod *v1.Pod, mode int8) error {
-
-	b, err := os.ReadFile(config.VKTokenFile) // just pass the file name
-	if err != nil {
-		log.G(ctx).Fatal(err)
-		return err
+// failureReason returns the reason of a failed InterLink call, as reported by InterLink and its sidecar when available
+func failureReason(err error) string {
+	var statusErr *client.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Reason()
 	}
-	token := string(b)
-
-	switch mode {
-	case CREATE:
-		var req commonIL.PodCreateRequests
-		req.Pod = *pod
-		startTime := time.Now()
-
-		timeNow := time.Now()
-		_, err := p.clientSet.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
-		if err != nil {
-			log.G(ctx).Warning("Deleted Pod before actual creation")
-			return nil
-		}
-
-		var failed bool
-
-		for _, volume := range pod.Spec.Volumes {
-			for {
-				if timeNow.Sub(startTime).Seconds() < time.Hour.Minutes()*5 {
-					if volume.ConfigMap != nil {
-						cfgmap, err := p.clientSet.CoreV1().ConfigMaps(pod.Namespace).Get(ctx, volume.ConfigMap.Name, metav1.GetOptions{})
-						if err != nil {
-							failed = true
-							log.G(ctx).Warning("Unable to find ConfigMap " + volume.ConfigMap.Name + " for pod " + pod.Name + ". Waiting for it to be initialized")
-							if pod.Status.Phase != "Initializing" {
-								pod.Status.Phase = "Initializing"
-								p.UpdatePod(ctx, pod)
-							}
-						} else {
-							failed = false
-							req.ConfigMaps = append(req.ConfigMaps, *cfgmap)
-						}
-					} else if volume.Secret != nil {
-						scrt, err := p.clientSet.CoreV1().Secrets(pod.Namespace).Get(ctx, volume.Secret.SecretName, metav1.GetOptions{})
-						if err != nil {
-							failed = true
-							log.G(ctx).Warning("Unable to find Secret " + volume.Secret.SecretName + " for pod " + pod.Name + ". Waiting for it to be initialized")
-							if pod.Status.Phase != "Initializing" {
-								pod.Status.Phase = "Initializing"
-								p.UpdatePod(ctx, pod)
-							}
-						} else {
-							failed = false
-							req.Secrets = append(req.Secrets, *scrt)
-						}
-					}
-
-					if failed {
-						time.Sleep(time.Second)
-						continue
-					} else {
-						pod.Status.Phase = v1.PodPending
-						p.UpdatePod(ctx, pod)
-						break
-					}
-				} else {
-					pod.Status.Phase = v1.PodFailed
-					pod.Status.Reason = "CFGMaps/Secrets not found"
-					for i, _ := range pod.Status.ContainerStatuses {
-						pod.Status.ContainerStatuses[i].Ready = false
-					}
-					p.UpdatePod(ctx, pod)
-					return errors.New("unable to retrieve ConfigMaps or Secrets. Check logs")
-				}
-			}
-		}
-
-		returnVal, err := createRequest(ctx, config, req, token)
-		if err != nil {
-			return err
-		}
-		log.G(ctx).Info(string(returnVal))
-
-	case DELETE:
-		req := pod
-		if pod.Status.Phase != "Initializing" {
-			returnVal, err := deleteRequest(ctx, config, req, token)
-			if err != nil {
-				return err
-			}
-			log.G(ctx).Info(string(returnVal))
-		}
+	var opErr *client.OperationError
+	if errors.As(err, &opErr) && opErr.Operation.Error != nil {
+		return opErr.Operation.Error.Error()
 	}
-	return nil
+	return err.Error()
 }
 
 // checkPodsStatus is regularly called by the VK itself at regular intervals of time to query InterLink for Pods' status.
 // It basically append all available pods registered to the VK to a slice and passes this slice to the statusRequest function.
 // After the statusRequest returns a response, this function uses that response to update every Pod and Container status.
-func checkPodsStatus(ctx context.Context, p *VirtualKubeletProvider, podsList []*v1.Pod, token string, config VirtualKubeletConfig) ([]commonIL.PodStatus, error) {
-	var returnVal []byte
+func checkPodsStatus(ctx context.Context, p *VirtualKubeletProvider, podsList []*v1.Pod) ([]commonIL.PodStatus, error) {
 	var ret []commonIL.PodStatus
 	var err error
 
 	//log.G(ctx).Debug(p.pods) //commented out because it's too verbose. uncomment to see all registered pods
 
-	returnVal, err = statusRequest(ctx, config, podsList, token)
+	ret, err = p.interLinkClient.Status(ctx, podsList)
 
 	if err != nil {
 		return nil, err
-	} else if returnVal != nil {
-		err = json.Unmarshal(returnVal, &ret)
-		if err != nil {
-			return nil, err
-		}
+	} else if ret != nil {
 		if podsList != nil {
 			for _, podStatus := range ret {
 
 				pod, err := p.GetPod(ctx, podStatus.PodNamespace, podStatus.PodName)
 				if err != nil {
-					updateCacheRequest(ctx, config, podStatus.PodUID, token)
+					p.interLinkClient.UpdateCache(ctx, podStatus.PodUID)
 					log.G(ctx).Warning("Error: " + err.Error() + "while getting statuses. Updating InterLink cache")
 					return nil, err
 				}
 
-				if podStatus.PodUID == string(pod.UID) {
+				if podStatus.PodUID == string(pod.UID) && podStatus.NotFound {
+					if attempt, failed := p.failedCreates.take(podStatus.PodUID); failed {
+						p.resendCreate(ctx, pod, attempt+1)
+					} else {
+						// e.g. its creation is still running: a Pod without containers must not be reported as completed
+						log.G(ctx).Info("Pod " + podStatus.PodNamespace + "/" + podStatus.PodName + " not found on the sidecar")
+					}
+				} else if podStatus.PodUID == string(pod.UID) {
+					if _, failed := p.failedCreates.take(podStatus.PodUID); failed {
+						// the failed creation reached the sidecar anyway
+						pod.Status.Message = ""
+					}
 					podRunning := false
 					podErrored := false
 					podCompleted := false
@@ -0,0 +1,116 @@
+package virtualkubelet
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// fieldRefValue returns the value of a field of the Pod, selected by a downward API fieldRef, formatted the way the kubelet does.
+// Labels and annotations are rendered one key="value" per line, sorted by key.
+func fieldRefValue(pod *v1.Pod, fieldPath string) (string, error) {
+	if key, ok := subscriptKey(fieldPath, "metadata.labels"); ok {
+		return pod.Labels[key], nil
+	}
+	if key, ok := subscriptKey(fieldPath, "metadata.annotations"); ok {
+		return pod.Annotations[key], nil
+	}
+
+	switch fieldPath {
+	case "metadata.name":
+		return pod.Name, nil
+	case "metadata.namespace":
+		return pod.Namespace, nil
+	case "metadata.uid":
+		return string(pod.UID), nil
+	case "metadata.labels":
+		return formatMap(pod.Labels), nil
+	case "metadata.annotations":
+		return formatMap(pod.Annotations), nil
+	case "spec.nodeName":
+		return pod.Spec.NodeName, nil
+	case "spec.serviceAccountName":
+		return pod.Spec.ServiceAccountName, nil
+	case "status.hostIP":
+		return pod.Status.HostIP, nil
+	case "status.podIP":
+		return pod.Status.PodIP, nil
+	case "status.podIPs":
+		var ips []string
+		for _, ip := range pod.Status.PodIPs {
+			ips = append(ips, ip.IP)
+		}
+		return strings.Join(ips, ","), nil
+	}
+	return "", fmt.Errorf("unsupported fieldRef %q", fieldPath)
+}
+
+// subscriptKey returns the key of a map subscript such as metadata.labels['key']
+func subscriptKey(fieldPath string, mapPath string) (string, bool) {
+	if !strings.HasPrefix(fieldPath, mapPath+"['") || !strings.HasSuffix(fieldPath, "']") {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(fieldPath, mapPath+"['"), "']"), true
+}
+
+func formatMap(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%s=%q", key, m[key]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// resourceFieldRefValue returns the request or limit of a resource of a container, selected by a downward API resourceFieldRef
+// and expressed in units of its divisor, rounded up. Unset limits default to the allocatable resources of the node, as on the kubelet.
+func resourceFieldRefValue(pod *v1.Pod, containerName string, ref *v1.ResourceFieldSelector, allocatable v1.ResourceList) (string, error) {
+	var container *v1.Container
+	for i := range pod.Spec.InitContainers {
+		if pod.Spec.InitContainers[i].Name == containerName {
+			container = &pod.Spec.InitContainers[i]
+		}
+	}
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == containerName {
+			container = &pod.Spec.Containers[i]
+		}
+	}
+	if container == nil {
+		return "", fmt.Errorf("container %q of resourceFieldRef %q not found", containerName, ref.Resource)
+	}
+
+	kind, name, found := strings.Cut(ref.Resource, ".")
+	if !found || kind != "limits" && kind != "requests" {
+		return "", fmt.Errorf("unsupported resourceFieldRef %q", ref.Resource)
+	}
+
+	var quantity resource.Quantity
+	var ok bool
+	if kind == "limits" {
+		quantity, ok = container.Resources.Limits[v1.ResourceName(name)]
+		if !ok {
+			quantity = allocatable[v1.ResourceName(name)]
+		}
+	} else {
+		quantity = container.Resources.Requests[v1.ResourceName(name)]
+	}
+
+	divisor := ref.Divisor
+	if divisor.IsZero() {
+		divisor = resource.MustParse("1")
+	}
+	if name == string(v1.ResourceCPU) {
+		return fmt.Sprint(int64(math.Ceil(float64(quantity.MilliValue()) / float64(divisor.MilliValue())))), nil
+	}
+	return fmt.Sprint(int64(math.Ceil(float64(quantity.Value()) / float64(divisor.Value())))), nil
+}
@@ -0,0 +1,58 @@
+package virtualkubelet
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/containerd/containerd/log"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// PortForward forwards a connection of the user to a port of the Pod, through a stream to the sidecar opened by InterLink.
+// It is called once per connection, so concurrent connections to the same Pod get their own streams.
+func (p *VirtualKubeletProvider) PortForward(ctx context.Context, namespace, podName string, port int32, stream io.ReadWriteCloser) error {
+	log.G(ctx).Infof("receive PortForward %s/%s:%d", namespace, podName, port)
+
+	pod, err := p.GetPod(ctx, namespace, podName)
+	if err != nil {
+		return err
+	}
+
+	conn, err := p.interLinkClient.PortForward(ctx, commonIL.PortForwardRequest{
+		Namespace: namespace,
+		PodName:   podName,
+		PodUID:    string(pod.UID),
+		Port:      port,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to forward port %d of %s/%s: %s", port, namespace, podName, failureReason(err))
+	}
+	defer conn.Close()
+	remote := commonIL.NewPortForwardStream(conn)
+
+	go func() {
+		_, err := io.Copy(remote, stream)
+		if err == nil {
+			// tells the Pod that the user is done sending
+			remote.Close()
+		}
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(stream, remote)
+		done <- err
+	}()
+
+	select {
+	case err = <-done:
+		if err != nil {
+			return fmt.Errorf("forwarding port %d of %s/%s: %w", port, namespace, podName, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,48 @@
+package virtualkubelet
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/containerd/containerd/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/intertwin-eu/interlink/pkg/interlink/client"
+)
+
+// configuredResources returns the resources of the node set in the configuration, used when the sidecars do not report theirs
+func configuredResources(config VirtualKubeletConfig) v1.ResourceList {
+	return v1.ResourceList{
+		"cpu":            resource.MustParse(config.CPU),
+		"memory":         resource.MustParse(config.Memory),
+		"pods":           resource.MustParse(config.Pods),
+		"nvidia.com/gpu": resource.MustParse(config.GPU),
+	}
+}
+
+// updateResources refreshes the capacity and the allocatable resources of the node with the ones reported by the sidecars.
+// The resources they do not report keep their configured value, and the configured values are restored when no sidecar reports its resources.
+// On any other failure the node keeps its current resources, until the next heartbeat.
+func (p *VirtualKubeletProvider) updateResources(ctx context.Context) {
+	capacity := configuredResources(p.config)
+	allocatable := configuredResources(p.config)
+
+	resources, err := p.interLinkClient.Resources(ctx)
+	if client.IsStatus(err, http.StatusNotImplemented) || client.IsNotFound(err) {
+		log.G(ctx).Debug("The sidecars do not report their resources, using the configured ones")
+	} else if err != nil {
+		log.G(ctx).Warning("Unable to get the resources of the sidecars: " + failureReason(err))
+		return
+	} else {
+		for name, quantity := range resources.Capacity {
+			capacity[name] = quantity
+		}
+		for name, quantity := range resources.AllocatableOrCapacity() {
+			allocatable[name] = quantity
+		}
+	}
+
+	p.node.Status.Capacity = capacity
+	p.node.Status.Allocatable = allocatable
+}
@@ -0,0 +1,183 @@
+package virtualkubelet
+
+import (
+	"context"
+
+	"github.com/containerd/containerd/log"
+	stats "github.com/virtual-kubelet/virtual-kubelet/node/api/statsv1alpha1"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// GetStatsSummary returns the resource usage of the pods known by this provider, as measured by their sidecars.
+// The pods whose sidecar does not report stats are listed without any usage, which metrics-server treats as unavailable.
+// The node usage is the sum of the usage of its pods.
+func (p *VirtualKubeletProvider) GetStatsSummary(ctx context.Context) (*stats.Summary, error) {
+	ctx, span := trace.StartSpan(ctx, "GetStatsSummary")
+	defer span.End()
+
+	res := &stats.Summary{
+		Node: stats.NodeStats{
+			NodeName:  p.nodeName,
+			StartTime: metav1.NewTime(p.startTime),
+		},
+	}
+
+	var pods []*v1.Pod
+	for _, pod := range p.pods {
+		pods = append(pods, pod)
+	}
+	if len(pods) == 0 {
+		return res, nil
+	}
+
+	reportedStats := make(map[string]commonIL.PodStats)
+	returnedStats, err := p.interLinkClient.Stats(ctx, pods)
+	if err != nil {
+		// stats are best effort: every pod is reported as unavailable
+		log.G(ctx).Warning("Unable to get the stats of the pods: " + failureReason(err))
+	}
+	for _, podStats := range returnedStats {
+		reportedStats[podStats.PodUID] = podStats
+	}
+
+	now := metav1.Now()
+	res.Node.CPU = &stats.CPUStats{Time: now}
+	res.Node.Memory = &stats.MemoryStats{Time: now}
+	for _, pod := range pods {
+		podStats, ok := reportedStats[string(pod.UID)]
+		if !ok {
+			log.G(ctx).Debugf("No stats available for pod %s/%s", pod.Namespace, pod.Name)
+			res.Pods = append(res.Pods, stats.PodStats{
+				PodRef:    stats.PodReference{Name: pod.Name, Namespace: pod.Namespace, UID: string(pod.UID)},
+				StartTime: podStartTime(pod),
+			})
+			continue
+		}
+
+		summary := podSummary(pod, podStats)
+		if summary.CPU != nil {
+			addUsage(&res.Node.CPU.UsageNanoCores, summary.CPU.UsageNanoCores)
+			addUsage(&res.Node.CPU.UsageCoreNanoSeconds, summary.CPU.UsageCoreNanoSeconds)
+		}
+		if summary.Memory != nil {
+			addUsage(&res.Node.Memory.UsageBytes, summary.Memory.UsageBytes)
+			addUsage(&res.Node.Memory.WorkingSetBytes, summary.Memory.WorkingSetBytes)
+			addUsage(&res.Node.Memory.RSSBytes, summary.Memory.RSSBytes)
+		}
+		res.Pods = append(res.Pods, summary)
+	}
+	if res.Node.CPU.UsageNanoCores == nil && res.Node.CPU.UsageCoreNanoSeconds == nil {
+		res.Node.CPU = nil
+	}
+	if res.Node.Memory.UsageBytes == nil && res.Node.Memory.WorkingSetBytes == nil && res.Node.Memory.RSSBytes == nil {
+		res.Node.Memory = nil
+	}
+
+	return res, nil
+}
+
+// podSummary converts the stats reported by the sidecar to the kubelet summary format.
+// The pod usage is the sum of the usage reported by its containers.
+func podSummary(pod *v1.Pod, podStats commonIL.PodStats) stats.PodStats {
+	measured := metav1.NewTime(podStats.Time)
+	summary := stats.PodStats{
+		PodRef:    stats.PodReference{Name: pod.Name, Namespace: pod.Namespace, UID: string(pod.UID)},
+		StartTime: podStartTime(pod),
+	}
+
+	for _, containerStats := range podStats.Containers {
+		container := stats.ContainerStats{
+			Name:      containerStats.Name,
+			StartTime: containerStartTime(pod, containerStats.Name),
+		}
+
+		if containerStats.CPU != nil {
+			container.CPU = &stats.CPUStats{
+				Time:                 measured,
+				UsageNanoCores:       containerStats.CPU.UsageNanoCores,
+				UsageCoreNanoSeconds: containerStats.CPU.UsageCoreNanoSeconds,
+			}
+			if summary.CPU == nil {
+				summary.CPU = &stats.CPUStats{Time: measured}
+			}
+			addUsage(&summary.CPU.UsageNanoCores, containerStats.CPU.UsageNanoCores)
+			addUsage(&summary.CPU.UsageCoreNanoSeconds, containerStats.CPU.UsageCoreNanoSeconds)
+		}
+
+		if containerStats.Memory != nil {
+			container.Memory = &stats.MemoryStats{
+				Time:            measured,
+				UsageBytes:      containerStats.Memory.UsageBytes,
+				WorkingSetBytes: containerStats.Memory.WorkingSetBytes,
+				RSSBytes:        containerStats.Memory.RSSBytes,
+			}
+			if summary.Memory == nil {
+				summary.Memory = &stats.MemoryStats{Time: measured}
+			}
+			addUsage(&summary.Memory.UsageBytes, containerStats.Memory.UsageBytes)
+			addUsage(&summary.Memory.WorkingSetBytes, containerStats.Memory.WorkingSetBytes)
+			addUsage(&summary.Memory.RSSBytes, containerStats.Memory.RSSBytes)
+		}
+
+		if containerStats.Filesystem != nil {
+			container.Rootfs = &stats.FsStats{
+				Time:           measured,
+				AvailableBytes: containerStats.Filesystem.AvailableBytes,
+				CapacityBytes:  containerStats.Filesystem.CapacityBytes,
+				UsedBytes:      containerStats.Filesystem.UsedBytes,
+				InodesUsed:     containerStats.Filesystem.InodesUsed,
+			}
+			if summary.EphemeralStorage == nil {
+				summary.EphemeralStorage = &stats.FsStats{Time: measured}
+			}
+			addUsage(&summary.EphemeralStorage.UsedBytes, containerStats.Filesystem.UsedBytes)
+			addUsage(&summary.EphemeralStorage.InodesUsed, containerStats.Filesystem.InodesUsed)
+		}
+
+		// the kubelet reports the network usage of the whole pod only
+		if containerStats.Network != nil {
+			if summary.Network == nil {
+				summary.Network = &stats.NetworkStats{Time: measured}
+			}
+			addUsage(&summary.Network.RxBytes, containerStats.Network.RxBytes)
+			addUsage(&summary.Network.RxErrors, containerStats.Network.RxErrors)
+			addUsage(&summary.Network.TxBytes, containerStats.Network.TxBytes)
+			addUsage(&summary.Network.TxErrors, containerStats.Network.TxErrors)
+		}
+
+		summary.Containers = append(summary.Containers, container)
+	}
+
+	return summary
+}
+
+// addUsage adds value, if reported, to total, allocating it on the first value
+func addUsage(total **uint64, value *uint64) {
+	if value == nil {
+		return
+	}
+	if *total == nil {
+		*total = new(uint64)
+	}
+	**total += *value
+}
+
+func podStartTime(pod *v1.Pod) metav1.Time {
+	if pod.Status.StartTime != nil {
+		return *pod.Status.StartTime
+	}
+	return pod.CreationTimestamp
+}
+
+func containerStartTime(pod *v1.Pod, containerName string) metav1.Time {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.Name == containerName && containerStatus.State.Running != nil {
+			return containerStatus.State.Running.StartedAt
+		}
+	}
+	return podStartTime(pod)
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"math/rand"
 	"os"
 	"strconv"
 	"time"
@@ -14,7 +13,6 @@ import (
 	"github.com/containerd/containerd/log"
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/node/api"
-	stats "github.com/virtual-kubelet/virtual-kubelet/node/api/statsv1alpha1"
 	"github.com/virtual-kubelet/virtual-kubelet/trace"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -23,6 +21,7 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 
 	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+	"github.com/intertwin-eu/interlink/pkg/interlink/client"
 )
 
 const (
@@ -37,6 +36,12 @@ const (
 	DELETE                = 1
 )
 
+// Defaults of the Pod creation batching, used when CreateBatchWindow and CreateBatchSize are unset
+const (
+	DefaultCreateBatchWindow = 200 * time.Millisecond
+	DefaultCreateBatchSize   = 100
+)
+
 func buildKeyFromNames(namespace string, name string) (string, error) {
 	return fmt.Sprintf("%s-%s", namespace, name), nil
 }
@@ -66,6 +71,11 @@ type VirtualKubeletProvider struct {
 	notifier             func(*v1.Pod)
 	onNodeChangeCallback func(*v1.Node)
 	clientSet            *kubernetes.Clientset
+	interLinkClient      *client.Client
+	createBatcher        *createBatcher
+	failedCreates        *failedCreates
+	dependencies         *dependencies
+	dependencyTimeout    time.Duration
 }
 
 // NewProviderConfig takes user-defined configuration and fills the Virtual Kubelet provider struct
@@ -123,22 +133,39 @@ func NewProviderConfig(
 			// },
 			Addresses:       []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: internalIP}},
 			DaemonEndpoints: v1.NodeDaemonEndpoints{KubeletEndpoint: v1.DaemonEndpoint{Port: int32(daemonEndpointPort)}},
-			Capacity: v1.ResourceList{
-				"cpu":            resource.MustParse(config.CPU),
-				"memory":         resource.MustParse(config.Memory),
-				"pods":           resource.MustParse(config.Pods),
-				"nvidia.com/gpu": resource.MustParse(config.GPU),
-			},
-			Allocatable: v1.ResourceList{
-				"cpu":            resource.MustParse(config.CPU),
-				"memory":         resource.MustParse(config.Memory),
-				"pods":           resource.MustParse(config.Pods),
-				"nvidia.com/gpu": resource.MustParse(config.GPU),
-			},
-			Conditions: nodeConditions(),
+			Capacity:        configuredResources(config),
+			Allocatable:     configuredResources(config),
+			Conditions:      nodeConditions(),
 		},
 	}
 
+	interLinkClient, err := newInterLinkClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	batchWindow := DefaultCreateBatchWindow
+	if config.CreateBatchWindow != "" {
+		batchWindow, err = time.ParseDuration(config.CreateBatchWindow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CreateBatchWindow %q: %w", config.CreateBatchWindow, err)
+		}
+	}
+	if config.CreateBatchSize <= 0 {
+		config.CreateBatchSize = DefaultCreateBatchSize
+	}
+	dependencyTimeout := DefaultDependencyTimeout
+	if config.DependencyTimeout != "" {
+		dependencyTimeout, err = time.ParseDuration(config.DependencyTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DependencyTimeout %q: %w", config.DependencyTimeout, err)
+		}
+	}
+	err = validateVolumeMappings(config.VolumeMappings)
+	if err != nil {
+		return nil, err
+	}
+
 	provider := VirtualKubeletProvider{
 		nodeName:           nodeName,
 		node:               &node,
@@ -148,6 +175,11 @@ func NewProviderConfig(
 		pods:               make(map[string]*v1.Pod),
 		config:             config,
 		startTime:          time.Now(),
+		interLinkClient:    interLinkClient,
+		createBatcher:      newCreateBatcher(interLinkClient, batchWindow, config.CreateBatchSize),
+		failedCreates:      newFailedCreates(),
+		dependencies:       newDependencies(),
+		dependencyTimeout:  dependencyTimeout,
 	}
 
 	return &provider, nil
@@ -241,7 +273,7 @@ func (p *VirtualKubeletProvider) nodeUpdate(ctx context.Context) {
 			return
 		case <-t.C:
 		}
-		bool, code, err := PingInterLink(ctx, p.config)
+		bool, code, err := PingInterLink(ctx, p.interLinkClient)
 		if err != nil || !bool {
 			p.node.Status.Conditions = []v1.NodeCondition{
 				{
@@ -333,6 +365,7 @@ func (p *VirtualKubeletProvider) nodeUpdate(ctx context.Context) {
 			}
 
 			log.G(ctx).Info("Ping succeded with exit code: ", code)
+			p.updateResources(ctx)
 			p.onNodeChangeCallback(p.node)
 		}
 		log.G(ctx).Info("endNodeLoop")
@@ -424,8 +457,11 @@ func (p *VirtualKubeletProvider) CreatePod(ctx context.Context, pod *v1.Pod) err
 
 	// Create pod asynchronously on the remote plugin
 	// we don't care, the statusLoop will eventually reconcile the status
+	// the creation is cancelled if the Pod is deleted while still waiting for its dependencies
+	creationCtx, done := p.dependencies.track(ctx, key)
 	go func() {
-		err := RemoteExecution(ctx, p.config, p, pod, CREATE)
+		defer done()
+		err := RemoteExecution(creationCtx, p.config, p, pod, CREATE)
 		if err != nil {
 			if err.Error() == "Deleted pod before actual creation" {
 				log.G(ctx).Warn(err)
@@ -488,6 +524,9 @@ func (p *VirtualKubeletProvider) DeletePod(ctx context.Context, pod *v1.Pod) (er
 		return errdefs.NotFound("pod not found")
 	}
 
+	p.dependencies.cancel(key)
+	p.failedCreates.take(string(pod.UID))
+
 	now := metav1.Now()
 	pod.Status.Reason = "VKProviderPodDeleted"
 
@@ -668,11 +707,6 @@ func (p *VirtualKubeletProvider) statusLoop(ctx context.Context) {
 		case <-t.C:
 		}
 
-		b, err := os.ReadFile(p.config.VKTokenFile) // just pass the file name
-		if err != nil {
-			fmt.Print(err)
-		}
-
 		var podsList []*v1.Pod
 		for _, pod := range p.pods {
 			if pod.Status.Phase != "Initializing" {
@@ -685,10 +719,13 @@ func (p *VirtualKubeletProvider) statusLoop(ctx context.Context) {
 		}
 
 		if podsList != nil {
-			_, err = checkPodsStatus(ctx, p, podsList, string(b), p.config)
+			spanCtx, span := trace.StartSpan(ctx, "CheckPodsStatus")
+			_, err := checkPodsStatus(spanCtx, p, podsList)
 			if err != nil {
 				log.G(ctx).Error(err)
+				span.SetStatus(err)
 			}
+			span.End()
 			for _, pod := range p.pods {
 				key, err := buildKey(pod)
 				if err != nil {
@@ -732,93 +769,20 @@ func (p *VirtualKubeletProvider) GetLogs(ctx context.Context, namespace, podName
 		log.G(ctx).Error(err)
 	}
 
+	pod, ok := p.pods[key]
+	if !ok {
+		return nil, errdefs.NotFoundf("pod \"%s/%s\" is not known to the provider", namespace, podName)
+	}
+
 	logsRequest := commonIL.LogStruct{
 		Namespace:     namespace,
-		PodUID:        string(p.pods[key].UID),
+		PodUID:        string(pod.UID),
 		PodName:       podName,
 		ContainerName: containerName,
 		Opts:          commonIL.ContainerLogOpts(opts),
 	}
 
-	return LogRetrieval(ctx, p.config, logsRequest)
-}
-
-// GetStatsSummary returns dummy stats for all pods known by this provider.
-func (p *VirtualKubeletProvider) GetStatsSummary(ctx context.Context) (*stats.Summary, error) {
-	var span trace.Span
-	_, span = trace.StartSpan(ctx, "GetStatsSummary") //nolint: ineffassign,staticcheck
-	defer span.End()
-
-	// Grab the current timestamp so we can report it as the time the stats were generated.
-	time := metav1.NewTime(time.Now())
-
-	// Create the Summary object that will later be populated with node and pod stats.
-	res := &stats.Summary{}
-
-	// Populate the Summary object with basic node stats.
-	res.Node = stats.NodeStats{
-		NodeName:  p.nodeName,
-		StartTime: metav1.NewTime(p.startTime),
-	}
-
-	// Populate the Summary object with dummy stats for each pod known by this provider.
-	for _, pod := range p.pods {
-		var (
-			// totalUsageNanoCores will be populated with the sum of the values of UsageNanoCores computes across all containers in the pod.
-			totalUsageNanoCores uint64
-			// totalUsageBytes will be populated with the sum of the values of UsageBytes computed across all containers in the pod.
-			totalUsageBytes uint64
-		)
-
-		// Create a PodStats object to populate with pod stats.
-		pss := stats.PodStats{
-			PodRef: stats.PodReference{
-				Name:      pod.Name,
-				Namespace: pod.Namespace,
-				UID:       string(pod.UID),
-			},
-			StartTime: pod.CreationTimestamp,
-		}
-
-		// Iterate over all containers in the current pod to compute dummy stats.
-		for _, container := range pod.Spec.Containers {
-			// Grab a dummy value to be used as the total CPU usage.
-			// The value should fit a uint32 in order to avoid overflows later on when computing pod stats.
-			dummyUsageNanoCores := uint64(rand.Uint32())
-			totalUsageNanoCores += dummyUsageNanoCores
-			// Create a dummy value to be used as the total RAM usage.
-			// The value should fit a uint32 in order to avoid overflows later on when computing pod stats.
-			dummyUsageBytes := uint64(rand.Uint32())
-			totalUsageBytes += dummyUsageBytes
-			// Append a ContainerStats object containing the dummy stats to the PodStats object.
-			pss.Containers = append(pss.Containers, stats.ContainerStats{
-				Name:      container.Name,
-				StartTime: pod.CreationTimestamp,
-				CPU: &stats.CPUStats{
-					Time:           time,
-					UsageNanoCores: &dummyUsageNanoCores,
-				},
-				Memory: &stats.MemoryStats{
-					Time:       time,
-					UsageBytes: &dummyUsageBytes,
-				},
-			})
-		}
-
-		// Populate the CPU and RAM stats for the pod and append the PodsStats object to the Summary object to be returned.
-		pss.CPU = &stats.CPUStats{
-			Time:           time,
-			UsageNanoCores: &totalUsageNanoCores,
-		}
-		pss.Memory = &stats.MemoryStats{
-			Time:       time,
-			UsageBytes: &totalUsageBytes,
-		}
-		res.Pods = append(res.Pods, pss)
-	}
-
-	// Return the dummy stats.
-	return res, nil
+	return LogRetrieval(ctx, p.interLinkClient, logsRequest)
 }
 
 // GetPods returns a list of all pods known to be "running" from the local disk cache info
@@ -829,12 +793,7 @@ func (p *VirtualKubeletProvider) RetrievePodsFromInterlink(ctx context.Context)
 
 	log.G(ctx).Info("Retrieving ALL cached InterLink Pods")
 
-	b, err := os.ReadFile(p.config.VKTokenFile) // just pass the file name
-	if err != nil {
-		log.G(ctx).Error(err)
-	}
-
-	cached_pods, err := checkPodsStatus(ctx, p, nil, string(b), p.config)
+	cached_pods, err := checkPodsStatus(ctx, p, nil)
 
 	for _, pod := range cached_pods {
 		retrievedPod, err := p.clientSet.CoreV1().Pods(pod.PodNamespace).Get(ctx, pod.PodName, metav1.GetOptions{})
@@ -0,0 +1,122 @@
+package virtualkubelet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/containerd/containerd/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// errNoVolumeMapping is returned when no VolumeMapping matches a claim. Waiting won't help, so the Pod fails right away.
+var errNoVolumeMapping = errors.New("no volume mapping")
+
+// validateVolumeMappings checks that every mapping selects some volumes and tells where to map them
+func validateVolumeMappings(mappings []VolumeMapping) error {
+	for i, mapping := range mappings {
+		claimSelector := mapping.ClaimName != "" || mapping.StorageClass != "" || len(mapping.Annotations) > 0
+		if claimSelector == (mapping.HostPath != "") {
+			return fmt.Errorf("VolumeMappings[%d] should select either claims, by ClaimName, StorageClass or Annotations, or hostPath volumes, by HostPath", i)
+		}
+		if mapping.RemotePath == "" && mapping.Driver == "" {
+			return fmt.Errorf("VolumeMappings[%d] should set RemotePath or Driver", i)
+		}
+	}
+	return nil
+}
+
+// mapVolume translates a PersistentVolumeClaim or hostPath volume of the Pod with the first matching VolumeMapping.
+// hostPath volumes no mapping matches are returned with their HostPath alone, and a warning, so that the plugin decides how to mount them.
+// Claims no mapping matches are returned as an error wrapping errNoVolumeMapping.
+func (p *VirtualKubeletProvider) mapVolume(ctx context.Context, pod *v1.Pod, volume v1.Volume) (commonIL.MappedVolume, error) {
+	mapped := commonIL.MappedVolume{Name: volume.Name}
+
+	if volume.HostPath != nil {
+		hostPath := path.Clean(volume.HostPath.Path)
+		mapped.HostPath = hostPath
+		for _, mapping := range p.config.VolumeMappings {
+			if mapping.HostPath == "" {
+				continue
+			}
+			relative, ok := pathBelow(hostPath, path.Clean(mapping.HostPath))
+			if !ok {
+				continue
+			}
+
+			mapped.Driver = mapping.Driver
+			mapped.Options = mapping.Options
+			mapped.ReadOnly = mapping.ReadOnly
+			if mapping.RemotePath != "" {
+				mapped.RemotePath = path.Join(expandMappingPath(mapping.RemotePath, pod.Namespace, ""), relative)
+			}
+			return mapped, nil
+		}
+		log.G(ctx).Warning("No volume mapping matches hostPath " + hostPath + " of volume " + volume.Name + " of pod " + pod.Name + ", passing it to the plugin as it is")
+		return mapped, nil
+	}
+
+	claimName := volume.PersistentVolumeClaim.ClaimName
+	claim, err := p.clientSet.CoreV1().PersistentVolumeClaims(pod.Namespace).Get(ctx, claimName, metav1.GetOptions{})
+	if err != nil {
+		return mapped, fmt.Errorf("unable to find PersistentVolumeClaim %s: %w", claimName, err)
+	}
+
+	for _, mapping := range p.config.VolumeMappings {
+		if !claimMatches(mapping, claim) {
+			continue
+		}
+
+		mapped.ClaimName = claimName
+		mapped.Driver = mapping.Driver
+		mapped.Options = mapping.Options
+		mapped.ReadOnly = mapping.ReadOnly || volume.PersistentVolumeClaim.ReadOnly
+		if mapping.RemotePath != "" {
+			mapped.RemotePath = expandMappingPath(mapping.RemotePath, pod.Namespace, claimName)
+		}
+		return mapped, nil
+	}
+	return mapped, fmt.Errorf("%w matches PersistentVolumeClaim %s of volume %s", errNoVolumeMapping, claimName, volume.Name)
+}
+
+// claimMatches returns true if the mapping selects claims and the claim satisfies every condition set in it
+func claimMatches(mapping VolumeMapping, claim *v1.PersistentVolumeClaim) bool {
+	if mapping.HostPath != "" {
+		return false
+	}
+	if mapping.ClaimName != "" && mapping.ClaimName != claim.Name {
+		return false
+	}
+	if mapping.StorageClass != "" && (claim.Spec.StorageClassName == nil || *claim.Spec.StorageClassName != mapping.StorageClass) {
+		return false
+	}
+	for key, value := range mapping.Annotations {
+		if claim.Annotations[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// pathBelow returns the path of p relative to parent, if p is parent itself or is below it
+func pathBelow(p string, parent string) (string, bool) {
+	if p == parent {
+		return "", true
+	}
+	if parent == "/" {
+		return strings.TrimPrefix(p, "/"), true
+	}
+	if strings.HasPrefix(p, parent+"/") {
+		return strings.TrimPrefix(p, parent+"/"), true
+	}
+	return "", false
+}
+
+func expandMappingPath(remotePath string, namespace string, claimName string) string {
+	return strings.NewReplacer("{namespace}", namespace, "{claim}", claimName).Replace(remotePath)
+}
@@ -0,0 +1,133 @@
+package virtualkubelet
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/containerd/containerd/log"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	commonIL "github.com/intertwin-eu/interlink/pkg/interlink"
+)
+
+// renderVolume renders the files of a projected or downwardAPI volume of the Pod, the way the kubelet would write them in the mount point.
+// A missing ConfigMap, Secret or key is returned as an error, unless optional, so that the creation waits for it.
+// Without withTokens, the service account tokens are left out: this checks the rest of the volume without issuing any token.
+// Service account tokens are requested for the audience and the expiration set in the source, and bound to the Pod.
+// A token that can't be issued is returned as an error too, so that the creation waits for it.
+// Known limit: tokens are requested once, at the creation, and never refreshed, since the sidecar has no access to the cluster
+// and InterLink has no way to update the files of a running Pod. They expire after the ExpirationSeconds of the source
+// (one hour by default), so Pods outliving them must ask for a longer expiration.
+func (p *VirtualKubeletProvider) renderVolume(ctx context.Context, pod *v1.Pod, volume v1.Volume, withTokens bool) (commonIL.ProjectedVolume, error) {
+	rendered := commonIL.ProjectedVolume{Name: volume.Name}
+
+	if volume.DownwardAPI != nil {
+		files, err := p.renderDownwardAPI(pod, volume.DownwardAPI.Items, commonIL.VolumeMode(volume.DownwardAPI.DefaultMode))
+		if err != nil {
+			return rendered, err
+		}
+		rendered.Files = files
+		return rendered, nil
+	}
+
+	defaultMode := commonIL.VolumeMode(volume.Projected.DefaultMode)
+	for _, source := range volume.Projected.Sources {
+		var files []commonIL.VolumeFile
+		var err error
+
+		switch {
+		case source.ConfigMap != nil:
+			files, err = p.renderConfigMapProjection(ctx, pod, source.ConfigMap, defaultMode)
+		case source.Secret != nil:
+			files, err = p.renderSecretProjection(ctx, pod, source.Secret, defaultMode)
+		case source.DownwardAPI != nil:
+			files, err = p.renderDownwardAPI(pod, source.DownwardAPI.Items, defaultMode)
+		case source.ServiceAccountToken != nil && !withTokens:
+			// checked only: the token is requested when the volume is rendered for the creation
+		case source.ServiceAccountToken != nil:
+			files, err = p.renderServiceAccountToken(ctx, pod, source.ServiceAccountToken, defaultMode)
+		default:
+			log.G(ctx).Warning("Unsupported source of projected volume " + volume.Name + " of pod " + pod.Name + ", skipping it")
+		}
+		if err != nil {
+			return rendered, fmt.Errorf("projected volume %s: %w", volume.Name, err)
+		}
+		rendered.Files = append(rendered.Files, files...)
+	}
+	return rendered, nil
+}
+
+func (p *VirtualKubeletProvider) renderConfigMapProjection(ctx context.Context, pod *v1.Pod, source *v1.ConfigMapProjection, defaultMode int32) ([]commonIL.VolumeFile, error) {
+	optional := isOptional(source.Optional)
+	cfgmap, err := p.getConfigMap(ctx, pod.Namespace, source.Name)
+	if err != nil {
+		if optional {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to find ConfigMap %s: %w", source.Name, err)
+	}
+
+	return commonIL.KeysToFiles("ConfigMap "+source.Name, commonIL.ConfigMapData(*cfgmap), source.Items, optional, defaultMode)
+}
+
+func (p *VirtualKubeletProvider) renderSecretProjection(ctx context.Context, pod *v1.Pod, source *v1.SecretProjection, defaultMode int32) ([]commonIL.VolumeFile, error) {
+	optional := isOptional(source.Optional)
+	secret, err := p.getSecret(ctx, pod.Namespace, source.Name)
+	if err != nil {
+		if optional {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to find Secret %s: %w", source.Name, err)
+	}
+
+	return commonIL.KeysToFiles("Secret "+source.Name, commonIL.SecretData(*secret), source.Items, optional, defaultMode)
+}
+
+func (p *VirtualKubeletProvider) renderDownwardAPI(pod *v1.Pod, items []v1.DownwardAPIVolumeFile, defaultMode int32) ([]commonIL.VolumeFile, error) {
+	var allocatable v1.ResourceList
+	if p.node != nil {
+		allocatable = p.node.Status.Allocatable
+	}
+
+	var files []commonIL.VolumeFile
+	for _, item := range items {
+		var value string
+		var err error
+		if item.FieldRef != nil {
+			value, err = fieldRefValue(pod, item.FieldRef.FieldPath)
+		} else if item.ResourceFieldRef != nil {
+			value, err = resourceFieldRefValue(pod, item.ResourceFieldRef.ContainerName, item.ResourceFieldRef, allocatable)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("downwardAPI file %s: %w", item.Path, err)
+		}
+		files = append(files, commonIL.VolumeFile{Path: path.Clean(item.Path), Content: []byte(value), Mode: commonIL.ItemMode(item.Mode, defaultMode)})
+	}
+	return files, nil
+}
+
+func (p *VirtualKubeletProvider) renderServiceAccountToken(ctx context.Context, pod *v1.Pod, source *v1.ServiceAccountTokenProjection, defaultMode int32) ([]commonIL.VolumeFile, error) {
+	serviceAccount := pod.Spec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+
+	tokenRequest := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: source.ExpirationSeconds,
+			BoundObjectRef:    &authenticationv1.BoundObjectReference{Kind: "Pod", APIVersion: "v1", Name: pod.Name, UID: pod.UID},
+		},
+	}
+	if source.Audience != "" {
+		tokenRequest.Spec.Audiences = []string{source.Audience}
+	}
+
+	token, err := p.clientSet.CoreV1().ServiceAccounts(pod.Namespace).CreateToken(ctx, serviceAccount, tokenRequest, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to request a token of service account %s: %w", serviceAccount, err)
+	}
+	return []commonIL.VolumeFile{{Path: path.Clean(source.Path), Content: []byte(token.Status.Token), Mode: defaultMode}}, nil
+}